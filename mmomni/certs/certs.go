@@ -0,0 +1,157 @@
+// Package certs validates the certificates that NGINX serves and
+// generates the local CA and certificates of the selfsigned TLS mode
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// ParseCertificates parses all the PEM encoded certificates of data,
+// in the order they appear
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+
+	return certs, nil
+}
+
+// ReadCertificates reads all the PEM encoded certificates of a file
+func ReadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	return certs, nil
+}
+
+// checkValidity returns an error if the certificate is not valid at
+// the given time
+func checkValidity(cert *x509.Certificate, now time.Time) error {
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("certificate %q is not valid until %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %q expired on %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ValidateChain checks that every certificate of the chain is signed
+// by the next one, starting with the leaf, and that all of them are
+// valid at the given time. The last certificate doesn't need to be a
+// root, as clients are expected to trust its issuer
+func ValidateChain(chain []*x509.Certificate, now time.Time) error {
+	for i, cert := range chain {
+		if err := checkValidity(cert, now); err != nil {
+			return err
+		}
+
+		if i+1 < len(chain) {
+			if err := cert.CheckSignatureFrom(chain[i+1]); err != nil {
+				return fmt.Errorf("certificate %q is not signed by the next certificate of the chain, %q: check the chain order: %w", cert.Subject.CommonName, chain[i+1].Subject.CommonName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CoversHostnames returns an error if any of the hostnames is not
+// covered by the certificate
+func CoversHostnames(cert *x509.Certificate, hostnames []string) error {
+	for _, hostname := range hostnames {
+		if err := cert.VerifyHostname(hostname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCustom checks that a certificate, its key and its optional
+// chain can be served for the hostnames: the key must match the
+// certificate, the chain must be in order, the certificate must cover
+// all the hostnames and none of them can be expired. The certificate
+// file may contain the chain after the certificate
+func ValidateCustom(certFile, keyFile, chainFile string, hostnames []string, now time.Time) error {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("key %q doesn't match certificate %q: %w", keyFile, certFile, err)
+	}
+
+	chain, err := ParseCertificates(certPEM)
+	if err != nil {
+		return fmt.Errorf("cannot parse %q: %w", certFile, err)
+	}
+
+	if chainFile != "" {
+		intermediates, err := ReadCertificates(chainFile)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, intermediates...)
+	}
+
+	if err := CoversHostnames(chain[0], hostnames); err != nil {
+		return fmt.Errorf("certificate %q doesn't cover all the hostnames: %w", certFile, err)
+	}
+
+	return ValidateChain(chain, now)
+}
+
+// ValidateClientCA checks that a bundle of CA certificates used to
+// verify client certificates can be parsed, and that all of them are
+// valid CA certificates at the given time
+func ValidateClientCA(path string, now time.Time) error {
+	bundle, err := ReadCertificates(path)
+	if err != nil {
+		return err
+	}
+
+	for _, cert := range bundle {
+		if !cert.IsCA {
+			return fmt.Errorf("certificate %q of %q is not a CA certificate", cert.Subject.CommonName, path)
+		}
+		if err := checkValidity(cert, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
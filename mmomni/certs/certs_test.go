@@ -0,0 +1,139 @@
+package certs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSelfSignedPaths(dir, name string) *SelfSignedPaths {
+	return &SelfSignedPaths{
+		CACert: filepath.Join(dir, name, "ca.pem"),
+		CAKey:  filepath.Join(dir, name, "ca.key"),
+		Cert:   filepath.Join(dir, name, "fullchain.pem"),
+		Key:    filepath.Join(dir, name, "privkey.pem"),
+	}
+}
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_certs_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	paths := newSelfSignedPaths(dir, "selfsigned")
+
+	generated, err := EnsureSelfSigned(paths, []string{"mattermost.example.com"}, now)
+	require.NoError(t, err)
+	require.True(t, generated)
+
+	info, err := os.Stat(paths.Key)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	caBytes, err := ioutil.ReadFile(paths.CACert)
+	require.NoError(t, err)
+
+	t.Run("Should not regenerate a current certificate", func(t *testing.T) {
+		generated, err := EnsureSelfSigned(paths, []string{"mattermost.example.com"}, now)
+		require.NoError(t, err)
+		require.False(t, generated)
+	})
+
+	t.Run("Should regenerate the certificate if the hostname changes, keeping the CA", func(t *testing.T) {
+		generated, err := EnsureSelfSigned(paths, []string{"chat.example.com"}, now)
+		require.NoError(t, err)
+		require.True(t, generated)
+
+		newCABytes, err := ioutil.ReadFile(paths.CACert)
+		require.NoError(t, err)
+		require.Equal(t, caBytes, newCABytes)
+	})
+
+	t.Run("Should regenerate the certificate before it expires", func(t *testing.T) {
+		generated, err := EnsureSelfSigned(paths, []string{"chat.example.com"}, now.Add(380*24*time.Hour))
+		require.NoError(t, err)
+		require.True(t, generated)
+	})
+
+	t.Run("Should support IP addresses", func(t *testing.T) {
+		ipPaths := newSelfSignedPaths(dir, "ip")
+		_, err := EnsureSelfSigned(ipPaths, []string{"10.0.0.1"}, now)
+		require.NoError(t, err)
+
+		certs, err := ReadCertificates(ipPaths.Cert)
+		require.NoError(t, err)
+		require.NoError(t, certs[0].VerifyHostname("10.0.0.1"))
+	})
+}
+
+func TestValidateCustom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_certs_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	paths := newSelfSignedPaths(dir, "valid")
+	_, err = EnsureSelfSigned(paths, []string{"mattermost.example.com"}, now)
+	require.NoError(t, err)
+
+	other := newSelfSignedPaths(dir, "other")
+	_, err = EnsureSelfSigned(other, []string{"mattermost.example.com"}, now)
+	require.NoError(t, err)
+
+	t.Run("Should accept a valid certificate with its chain", func(t *testing.T) {
+		require.NoError(t, ValidateCustom(paths.Cert, paths.Key, paths.CACert, []string{"mattermost.example.com"}, now))
+		require.NoError(t, ValidateCustom(paths.Cert, paths.Key, "", []string{"mattermost.example.com"}, now))
+	})
+
+	t.Run("Should accept a certificate file that contains the chain", func(t *testing.T) {
+		certBytes, err := ioutil.ReadFile(paths.Cert)
+		require.NoError(t, err)
+		caBytes, err := ioutil.ReadFile(paths.CACert)
+		require.NoError(t, err)
+
+		fullchain := filepath.Join(dir, "fullchain.pem")
+		require.NoError(t, ioutil.WriteFile(fullchain, append(certBytes, caBytes...), 0644))
+		require.NoError(t, ValidateCustom(fullchain, paths.Key, "", []string{"mattermost.example.com"}, now))
+	})
+
+	t.Run("Should reject a key that doesn't match", func(t *testing.T) {
+		require.Error(t, ValidateCustom(paths.Cert, other.Key, "", []string{"mattermost.example.com"}, now))
+	})
+
+	t.Run("Should reject a chain in the wrong order", func(t *testing.T) {
+		require.Error(t, ValidateCustom(paths.Cert, paths.Key, other.CACert, []string{"mattermost.example.com"}, now))
+	})
+
+	t.Run("Should reject a certificate that doesn't cover the fqdn", func(t *testing.T) {
+		require.Error(t, ValidateCustom(paths.Cert, paths.Key, "", []string{"chat.example.com"}, now))
+	})
+
+	t.Run("Should reject a certificate that doesn't cover an alias", func(t *testing.T) {
+		require.Error(t, ValidateCustom(paths.Cert, paths.Key, "", []string{"mattermost.example.com", "chat.example.com"}, now))
+	})
+
+	t.Run("Should reject an expired certificate", func(t *testing.T) {
+		require.Error(t, ValidateCustom(paths.Cert, paths.Key, "", []string{"mattermost.example.com"}, now.Add(400*24*time.Hour)))
+	})
+}
+
+func TestValidateClientCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_certs_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	paths := newSelfSignedPaths(dir, "clients")
+	_, err = EnsureSelfSigned(paths, []string{"mattermost.example.com"}, now)
+	require.NoError(t, err)
+
+	require.NoError(t, ValidateClientCA(paths.CACert, now))
+	require.Error(t, ValidateClientCA(paths.Cert, now))
+	require.Error(t, ValidateClientCA(paths.CACert, now.Add(11*365*24*time.Hour)))
+	require.Error(t, ValidateClientCA(filepath.Join(dir, "missing.pem"), now))
+}
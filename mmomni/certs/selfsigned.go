@@ -0,0 +1,193 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caValidity = 10 * 365 * 24 * time.Hour
+	// certificates of private CAs with a validity longer than 825 days
+	// are rejected by some clients, and 397 days is the maximum for
+	// public CAs, so we stay on the safe side
+	selfSignedValidity = 397 * 24 * time.Hour
+	// SelfSignedRenewBefore is the time before its expiration when the
+	// selfsigned certificate is regenerated
+	SelfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// SelfSignedPaths contains the files of the selfsigned mode
+type SelfSignedPaths struct {
+	CACert string
+	CAKey  string
+	Cert   string
+	Key    string
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func writeKey(path string, key crypto.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "PRIVATE KEY", der, 0600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+// loadOrCreateCA reads the local CA, generating it if it doesn't
+// exist or if it has expired
+func loadOrCreateCA(paths *SelfSignedPaths, now time.Time) (*x509.Certificate, crypto.Signer, error) {
+	if pair, err := tls.LoadX509KeyPair(paths.CACert, paths.CAKey); err == nil {
+		ca, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		signer, ok := pair.PrivateKey.(crypto.Signer)
+		if ok && checkValidity(ca, now.Add(SelfSignedRenewBefore)) == nil {
+			return ca, signer, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("cannot read local CA: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Mattermost Omnibus local CA", Organization: []string{"Mattermost Omnibus"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writeKey(paths.CAKey, key); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(paths.CACert, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// selfSignedIsCurrent returns true if the certificate exists, covers
+// the hostnames, is signed by the CA and doesn't need to be renewed
+func selfSignedIsCurrent(paths *SelfSignedPaths, ca *x509.Certificate, hostnames []string, now time.Time) bool {
+	if _, err := tls.LoadX509KeyPair(paths.Cert, paths.Key); err != nil {
+		return false
+	}
+
+	chain, err := ReadCertificates(paths.Cert)
+	if err != nil {
+		return false
+	}
+
+	cert := chain[0]
+	if cert.CheckSignatureFrom(ca) != nil || checkValidity(cert, now.Add(SelfSignedRenewBefore)) != nil {
+		return false
+	}
+
+	return CoversHostnames(cert, hostnames) == nil
+}
+
+// EnsureSelfSigned makes sure that a certificate for the hostnames,
+// signed by the local CA, exists and is not about to expire. The CA
+// is generated the first time, so it can be distributed to the
+// clients once. Returns true if a new certificate was generated
+func EnsureSelfSigned(paths *SelfSignedPaths, hostnames []string, now time.Time) (bool, error) {
+	if len(hostnames) == 0 {
+		return false, fmt.Errorf("at least one hostname is required")
+	}
+
+	ca, caKey, err := loadOrCreateCA(paths, now)
+	if err != nil {
+		return false, err
+	}
+
+	if selfSignedIsCurrent(paths, ca, hostnames, now) {
+		return false, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return false, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hostnames[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, hostname := range hostnames {
+		if ip := net.ParseIP(hostname); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, hostname)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return false, err
+	}
+
+	if err := writeKey(paths.Key, key); err != nil {
+		return false, err
+	}
+	if err := writePEM(paths.Cert, "CERTIFICATE", der, 0644); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
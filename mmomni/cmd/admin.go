@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+func AdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Runs common administration tasks",
+		Long:  `Runs common Mattermost administration tasks through the local mode of the server, so a freshly installed platform can be set up without using the browser. For other tasks, use "mmomni mm"`,
+	}
+
+	cmd.AddCommand(
+		AdminCreateTeamCmd(),
+		AdminCreateUserCmd(),
+		AdminResetPasswordCmd(),
+	)
+
+	return cmd
+}
+
+func AdminCreateUserCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-user",
+		Short: "Creates a system admin user",
+		Long:  "Creates a user with the system admin role. If no password is provided, a random one is generated and printed",
+		Example: `  $ mmomni admin create-user --username admin --email admin@example.com
+  $ mmomni admin create-user --username admin --email admin@example.com --team myteam`,
+		Args: cobra.NoArgs,
+		Run:  adminCreateUserCmdF,
+	}
+
+	cmd.Flags().String("username", "", "The username of the user")
+	_ = cmd.MarkFlagRequired("username")
+	cmd.Flags().String("email", "", "The email address of the user")
+	_ = cmd.MarkFlagRequired("email")
+	cmd.Flags().String("password", "", "The password of the user. If empty, a random password is generated")
+	cmd.Flags().String("team", "", "The name of a team to add the user to")
+
+	return cmd
+}
+
+func AdminCreateTeamCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "create-team",
+		Short:   "Creates a team",
+		Example: `  $ mmomni admin create-team --name myteam --display-name "My Team" --member admin`,
+		Args:    cobra.NoArgs,
+		Run:     adminCreateTeamCmdF,
+	}
+
+	cmd.Flags().String("name", "", "The name of the team, used in its URL")
+	_ = cmd.MarkFlagRequired("name")
+	cmd.Flags().String("display-name", "", "The display name of the team. Defaults to the team name")
+	cmd.Flags().Bool("private", false, "Creates an invite only team")
+	cmd.Flags().StringSlice("member", []string{}, "The username or email of a user to add to the team. Can be repeated")
+
+	return cmd
+}
+
+func AdminResetPasswordCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "reset-password <user>",
+		Short:   "Resets the password of a user",
+		Long:    "Sets a new password for a user, identified by its username or email. If no password is provided, a random one is generated and printed",
+		Example: `  $ mmomni admin reset-password admin`,
+		Args:    cobra.ExactArgs(1),
+		Run:     adminResetPasswordCmdF,
+	}
+
+	cmd.Flags().String("password", "", "The new password. If empty, a random password is generated")
+
+	return cmd
+}
+
+// createAdminUser creates a user with the system admin role
+func createAdminUser(username, email, password string) error {
+	user := map[string]string{"username": username, "email": email, "password": password}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := localAPIRequest(http.MethodPost, "/api/v4/users", user, &created); err != nil {
+		return err
+	}
+
+	return setSystemAdminRoles(created.ID)
+}
+
+// grantSystemAdmin gives the system admin role to an existing user,
+// identified by username or email
+func grantSystemAdmin(user string) error {
+	userID, err := localAPIUserID(user)
+	if err != nil {
+		return err
+	}
+	return setSystemAdminRoles(userID)
+}
+
+func setSystemAdminRoles(userID string) error {
+	roles := map[string]string{"roles": "system_user system_admin"}
+	return localAPIRequest(http.MethodPut, "/api/v4/users/"+userID+"/roles", roles, nil)
+}
+
+// createTeam creates a team, using the name as display name if the
+// latter is empty
+func createTeam(name, displayName string, private bool) error {
+	if displayName == "" {
+		displayName = name
+	}
+
+	args := []string{"team", "create", "--name", name, "--display-name", displayName}
+	if private {
+		args = append(args, "--private")
+	}
+
+	_, err := runMmctl(args...)
+	return err
+}
+
+// addTeamMembers adds a list of users, identified by username or
+// email, to a team
+func addTeamMembers(team string, users ...string) error {
+	_, err := runMmctl(append([]string{"team", "users", "add", team}, users...)...)
+	return err
+}
+
+// resetPassword sets the password of a user, identified by username
+// or email
+func resetPassword(user, password string) error {
+	userID, err := localAPIUserID(user)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]string{"new_password": password}
+	return localAPIRequest(http.MethodPut, "/api/v4/users/"+userID+"/password", body, nil)
+}
+
+func adminCreateUserCmdF(cmd *cobra.Command, _ []string) {
+	username, _ := cmd.Flags().GetString("username")
+	email, _ := cmd.Flags().GetString("email")
+	password, _ := cmd.Flags().GetString("password")
+	team, _ := cmd.Flags().GetString("team")
+
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = CreatePassword(); err != nil {
+			errAndExit(fmt.Errorf("error generating password: %w", err))
+		}
+	}
+
+	if err := createAdminUser(username, email, password); err != nil {
+		errAndExit(fmt.Errorf("error creating user %q: %w", username, err))
+	}
+	fmt.Printf("System admin %q created\n", username)
+
+	if team != "" {
+		if err := addTeamMembers(team, username); err != nil {
+			errAndExit(fmt.Errorf("error adding user %q to team %q: %w", username, team, err))
+		}
+		fmt.Printf("User %q added to team %q\n", username, team)
+	}
+
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+}
+
+func adminCreateTeamCmdF(cmd *cobra.Command, _ []string) {
+	name, _ := cmd.Flags().GetString("name")
+	displayName, _ := cmd.Flags().GetString("display-name")
+	private, _ := cmd.Flags().GetBool("private")
+	members, _ := cmd.Flags().GetStringSlice("member")
+
+	if err := createTeam(name, displayName, private); err != nil {
+		errAndExit(fmt.Errorf("error creating team %q: %w", name, err))
+	}
+	fmt.Printf("Team %q created\n", name)
+
+	if len(members) != 0 {
+		if err := addTeamMembers(name, members...); err != nil {
+			errAndExit(fmt.Errorf("error adding members to team %q: %w", name, err))
+		}
+		fmt.Printf("%d members added to team %q\n", len(members), name)
+	}
+}
+
+func adminResetPasswordCmdF(cmd *cobra.Command, args []string) {
+	password, _ := cmd.Flags().GetString("password")
+
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = CreatePassword(); err != nil {
+			errAndExit(fmt.Errorf("error generating password: %w", err))
+		}
+	}
+
+	if err := resetPassword(args[0], password); err != nil {
+		errAndExit(fmt.Errorf("error resetting password of user %q: %w", args[0], err))
+	}
+	fmt.Printf("Password of user %q reset\n", args[0])
+
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"time"
 
@@ -80,8 +79,7 @@ func backupCmdF(cmd *cobra.Command, _ []string) {
 
 	// Runs pgdump
 	dumpFilepath := filepath.Join(dir, "database.dump")
-	pgDumpCmd := exec.Command("pg_dump", "mattermost", "-Fc", "-f", dumpFilepath, "-w", "-U", *config.DBUser, "-h", "localhost")
-	pgDumpCmd.Env = append(pgDumpCmd.Env, "PGPASSWORD="+*config.DBPassword)
+	pgDumpCmd := postgresClientCommand(config, "pg_dump", "-Fc", "-f", dumpFilepath, "-w")
 	pgDumpCmd.Stdout = os.Stdout
 	pgDumpCmd.Stderr = os.Stderr
 	if err := pgDumpCmd.Run(); err != nil {
@@ -98,6 +96,19 @@ func backupCmdF(cmd *cobra.Command, _ []string) {
 	if err := ioutil.WriteFile(configFilepath, fileBytes, 0600); err != nil {
 		errAndExit(fmt.Errorf("error writing configuration to %q: %w", configFilepath, err))
 	}
+	backupFiles := []string{configFilepath, dumpFilepath}
+
+	// Copies the secrets file to tmp directory if it exists
+	secretsBytes, err := ioutil.ReadFile(*config.SecretsFile)
+	if err != nil && !os.IsNotExist(err) {
+		errAndExit(fmt.Errorf("error reading secrets file in %q: %w", *config.SecretsFile, err))
+	} else if err == nil {
+		secretsFilepath := filepath.Join(dir, model.SECRETS_FILENAME)
+		if err := ioutil.WriteFile(secretsFilepath, secretsBytes, 0600); err != nil {
+			errAndExit(fmt.Errorf("error writing secrets to %q: %w", secretsFilepath, err))
+		}
+		backupFiles = append(backupFiles, secretsFilepath)
+	}
 
 	// Creates tarball with config, dump and data folder
 	tarball, err := os.Create(output)
@@ -113,7 +124,7 @@ func backupCmdF(cmd *cobra.Command, _ []string) {
 	defer tw.Close()
 
 	// Adds basic files to the tarball's root path
-	if err := addFilesToTarball(tw, []string{configFilepath, dumpFilepath}, ""); err != nil {
+	if err := addFilesToTarball(tw, backupFiles, ""); err != nil {
 		errAndExit(fmt.Errorf("error adding files to tarball: %w", err))
 	}
 
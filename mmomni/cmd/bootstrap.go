@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// bootstrapPending returns true if the configuration has a bootstrap
+// section that hasn't been applied yet
+func bootstrapPending(config *model.Config, donePath string) bool {
+	if config.Bootstrap == nil {
+		return false
+	}
+
+	_, err := os.Stat(donePath)
+	return os.IsNotExist(err)
+}
+
+// readAdminPassword reads the bootstrap admin password from path. If
+// the file doesn't exist, a random password is generated and written
+// to it, only readable by root
+func readAdminPassword(path string) (string, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		password, err := CreatePassword()
+		if err != nil {
+			return "", fmt.Errorf("error generating admin password: %w", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(password+"\n"), 0600); err != nil {
+			return "", fmt.Errorf("error writing admin password file: %w", err)
+		}
+		fmt.Printf("Bootstrap: admin password generated and stored in %q\n", path)
+		return password, nil
+	} else if err != nil {
+		return "", fmt.Errorf("error reading admin password file: %w", err)
+	}
+
+	password := strings.TrimSpace(string(fileBytes))
+	if password == "" {
+		return "", fmt.Errorf("admin password file %q is empty", path)
+	}
+	return password, nil
+}
+
+// searchResultContains checks if the JSON output of a mmctl search
+// command, which can be a single object or a list of them, contains
+// an object whose field has the given value
+func searchResultContains(out []byte, field, value string) bool {
+	var results []map[string]interface{}
+	if err := json.Unmarshal(out, &results); err != nil {
+		var result map[string]interface{}
+		if err := json.Unmarshal(out, &result); err != nil {
+			return false
+		}
+		results = []map[string]interface{}{result}
+	}
+
+	for _, result := range results {
+		if v, ok := result[field].(string); ok && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func userExists(username string) bool {
+	out, err := runMmctl("user", "search", username, "--format", "json")
+	return err == nil && searchResultContains(out, "username", username)
+}
+
+func teamExists(name string) bool {
+	out, err := runMmctl("team", "search", name, "--format", "json")
+	return err == nil && searchResultContains(out, "name", name)
+}
+
+// applyBootstrap creates the admin user and the team of the bootstrap
+// section, skipping the ones that already exist so it can be retried
+// after a partial failure
+func applyBootstrap(bootstrap *model.BootstrapConfig) error {
+	password, err := readAdminPassword(*bootstrap.AdminPasswordFile)
+	if err != nil {
+		return err
+	}
+
+	// the server has just been restarted, so we wait for its local
+	// mode socket to be available
+	if _, err := waitForMmctl(2*time.Minute, "system", "version"); err != nil {
+		return fmt.Errorf("error connecting to Mattermost: %w", err)
+	}
+
+	username := *bootstrap.AdminUsername
+	if userExists(username) {
+		// a previous bootstrap may have created the user and failed
+		// before setting its roles
+		if err := grantSystemAdmin(username); err != nil {
+			return fmt.Errorf("error setting the system admin role of user %q: %w", username, err)
+		}
+		fmt.Printf("Bootstrap: user %q already exists\n", username)
+	} else {
+		if err := createAdminUser(username, *bootstrap.AdminEmail, password); err != nil {
+			return fmt.Errorf("error creating admin user %q: %w", username, err)
+		}
+		fmt.Printf("Bootstrap: system admin %q created\n", username)
+	}
+
+	team := *bootstrap.TeamName
+	if team == "" {
+		return nil
+	}
+
+	if teamExists(team) {
+		fmt.Printf("Bootstrap: team %q already exists\n", team)
+	} else {
+		if err := createTeam(team, *bootstrap.TeamDisplayName, false); err != nil {
+			return fmt.Errorf("error creating team %q: %w", team, err)
+		}
+		fmt.Printf("Bootstrap: team %q created\n", team)
+	}
+
+	if err := addTeamMembers(team, username); err != nil {
+		return fmt.Errorf("error adding user %q to team %q: %w", username, team, err)
+	}
+
+	return nil
+}
+
+// runBootstrap applies the bootstrap section of the configuration if
+// it is pending. The lock file keeps the server unreachable until the
+// bootstrap succeeds, and the done file prevents it from being
+// applied again
+func runBootstrap(config *model.Config, donePath, lockPath string) error {
+	if !bootstrapPending(config, donePath) {
+		return nil
+	}
+
+	if err := applyBootstrap(config.Bootstrap); err != nil {
+		return err
+	}
+
+	done := fmt.Sprintf("bootstrap applied on %s\n", time.Now().Format(time.RFC3339))
+	if err := ioutil.WriteFile(donePath, []byte(done), 0600); err != nil {
+		return fmt.Errorf("error writing bootstrap done file: %w", err)
+	}
+
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing bootstrap lock file: %w", err)
+	}
+
+	return nil
+}
+
+// lockBootstrap creates the lock file that makes NGINX reject all the
+// requests until the bootstrap is applied
+func lockBootstrap(lockPath string) error {
+	return ioutil.WriteFile(lockPath, []byte("mmomni bootstrap pending\n"), 0644)
+}
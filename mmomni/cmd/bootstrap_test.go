@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func TestBootstrapPending(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_bootstrap_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	donePath := filepath.Join(dir, "mmomni.bootstrap.done")
+
+	config := &model.Config{}
+	require.False(t, bootstrapPending(config, donePath), "no bootstrap section")
+
+	config.Bootstrap = &model.BootstrapConfig{}
+	require.True(t, bootstrapPending(config, donePath))
+
+	require.NoError(t, ioutil.WriteFile(donePath, []byte("done"), 0600))
+	require.False(t, bootstrapPending(config, donePath), "bootstrap already applied")
+}
+
+func TestReadAdminPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_bootstrap_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("Should generate the password if the file doesn't exist", func(t *testing.T) {
+		path := filepath.Join(dir, "generated")
+
+		password, err := readAdminPassword(path)
+		require.NoError(t, err)
+		require.Len(t, password, PasswdSize)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		again, err := readAdminPassword(path)
+		require.NoError(t, err)
+		require.Equal(t, password, again)
+	})
+
+	t.Run("Should read and trim an existing password", func(t *testing.T) {
+		path := filepath.Join(dir, "existing")
+		require.NoError(t, ioutil.WriteFile(path, []byte("  s3cr3t-password\n"), 0600))
+
+		password, err := readAdminPassword(path)
+		require.NoError(t, err)
+		require.Equal(t, "s3cr3t-password", password)
+	})
+
+	t.Run("Should fail with an empty file", func(t *testing.T) {
+		path := filepath.Join(dir, "empty")
+		require.NoError(t, ioutil.WriteFile(path, []byte("\n"), 0600))
+
+		_, err := readAdminPassword(path)
+		require.Error(t, err)
+	})
+}
+
+func TestSearchResultContains(t *testing.T) {
+	require.True(t, searchResultContains([]byte(`{"id": "abc", "username": "admin"}`), "username", "admin"))
+	require.True(t, searchResultContains([]byte(`[{"username": "other"}, {"username": "admin"}]`), "username", "admin"))
+	require.False(t, searchResultContains([]byte(`[{"username": "administrator"}]`), "username", "admin"))
+	require.False(t, searchResultContains([]byte(`[]`), "name", "myteam"))
+	require.False(t, searchResultContains([]byte(`Unable to find team 'myteam'`), "name", "myteam"))
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/certs"
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// certExpiryWarningDays is the number of days before the expiration
+// of the certificate when mmomni status starts warning about it
+const certExpiryWarningDays = 14
+
+func CertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cert",
+		Short: "Manages the TLS certificate",
+		Long:  "Manages the certificate that NGINX serves when https is enabled, configured in the tls section of /etc/mattermost/mmomni.yml",
+	}
+
+	cmd.AddCommand(
+		CertRenewCmd(),
+		CertStatusCmd(),
+	)
+
+	return cmd
+}
+
+func CertStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "status",
+		Short:   "Shows the certificate status",
+		Long:    "Shows the issuer, the names, the expiration date and the renewal status of the certificate that NGINX serves",
+		Example: `  $ mmomni cert status`,
+		Args:    cobra.NoArgs,
+		Run:     certStatusCmdF,
+	}
+}
+
+func CertRenewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Renews the certificate",
+		Long: `Renews the certificate if it is close to its expiration, or always with the --force flag, and reloads NGINX to serve the new certificate
+
+In the letsencrypt mode the certificate is renewed with certbot, and in the selfsigned mode a new certificate is signed by the local CA. Custom certificates need to be renewed outside of Omnibus`,
+		Example: `  $ mmomni cert renew
+  $ mmomni cert renew --force`,
+		Args: cobra.NoArgs,
+		Run:  certRenewCmdF,
+	}
+
+	cmd.Flags().Bool("force", false, "Renews the certificate even if it is not close to its expiration")
+
+	return cmd
+}
+
+// daysRemaining returns the number of full days until the certificate
+// expires, negative if it has already expired
+func daysRemaining(cert *x509.Certificate, now time.Time) int {
+	return int(cert.NotAfter.Sub(now).Hours() / 24)
+}
+
+// certNames returns the DNS names and IP addresses of a certificate
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// certExpiryWarning returns a warning if the certificate at path
+// expires in less than certExpiryWarningDays, or can't be read
+func certExpiryWarning(path string, now time.Time) string {
+	chain, err := certs.ReadCertificates(path)
+	if err != nil {
+		return fmt.Sprintf("cannot read certificate: %s", err)
+	}
+
+	days := daysRemaining(chain[0], now)
+	if days < 0 {
+		return fmt.Sprintf("certificate %q expired on %s", path, chain[0].NotAfter.Format(time.RFC3339))
+	}
+	if days < certExpiryWarningDays {
+		return fmt.Sprintf("certificate %q expires in %d days, on %s", path, days, chain[0].NotAfter.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// renewalStatus describes how the certificate of the configured mode
+// is renewed
+func renewalStatus(config *model.Config) string {
+	switch config.TLSMode() {
+	case model.TLS_MODE_CUSTOM:
+		return "manual, custom certificates are renewed outside of Omnibus"
+	case model.TLS_MODE_SELFSIGNED:
+		return fmt.Sprintf("renewed by reconfigure and \"mmomni cert renew\" %d days before expiring", int(certs.SelfSignedRenewBefore.Hours()/24))
+	}
+
+	out, err := exec.Command("systemctl", "show", "certbot.timer", "--property", "ActiveState", "--property", "NextElapseUSecRealtime").CombinedOutput()
+	if err != nil {
+		return fmt.Sprintf("cannot check certbot.timer: %s", err)
+	}
+
+	properties := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			properties[parts[0]] = parts[1]
+		}
+	}
+
+	if properties["ActiveState"] != "active" {
+		return fmt.Sprintf("certbot.timer is %s, the certificate is only renewed by reconfigure and \"mmomni cert renew\"", properties["ActiveState"])
+	}
+	return fmt.Sprintf("certbot.timer is active, next run %s", properties["NextElapseUSecRealtime"])
+}
+
+// certificateRenewed returns true if the certificate after a renewal
+// differs from the one before it, which is nil if it didn't exist
+func certificateRenewed(before, after *x509.Certificate) bool {
+	return before == nil || !bytes.Equal(before.Raw, after.Raw)
+}
+
+// readLeafCertificate returns the first certificate of the file at
+// path, or nil if it cannot be read
+func readLeafCertificate(path string) *x509.Certificate {
+	chain, err := certs.ReadCertificates(path)
+	if err != nil {
+		return nil
+	}
+	return chain[0]
+}
+
+func readCertConfig() *model.Config {
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if !*config.HTTPS {
+		errAndExit(fmt.Errorf("https is disabled in %q", model.CONFIGPATH))
+	}
+
+	return config
+}
+
+func certStatusCmdF(_ *cobra.Command, _ []string) {
+	config := readCertConfig()
+	certPath, _ := config.TLSCertificate()
+
+	chain, err := certs.ReadCertificates(certPath)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading certificate: %w", err))
+	}
+
+	cert := chain[0]
+	now := time.Now()
+	fmt.Printf("Mode: %s\n", config.TLSMode())
+	fmt.Printf("Certificate: %s\n", certPath)
+	fmt.Printf("Subject: %s\n", cert.Subject)
+	fmt.Printf("Issuer: %s\n", cert.Issuer)
+	fmt.Printf("Names: %s\n", strings.Join(certNames(cert), ", "))
+	fmt.Printf("Valid from: %s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Printf("Valid until: %s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Printf("Days remaining: %d\n", daysRemaining(cert, now))
+	fmt.Printf("Renewal: %s\n", renewalStatus(config))
+
+	if warning := certExpiryWarning(certPath, now); warning != "" {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
+}
+
+func certRenewCmdF(cmd *cobra.Command, _ []string) {
+	force, _ := cmd.Flags().GetBool("force")
+	config := readCertConfig()
+	certPath, _ := config.TLSCertificate()
+	before := readLeafCertificate(certPath)
+
+	switch config.TLSMode() {
+	case model.TLS_MODE_CUSTOM:
+		errAndExit(fmt.Errorf("custom certificates need to be renewed outside of Omnibus, run \"mmomni reconfigure\" after replacing them"))
+	case model.TLS_MODE_SELFSIGNED:
+		paths := selfSignedPaths(config)
+		if force {
+			if err := os.Remove(paths.Cert); err != nil && !os.IsNotExist(err) {
+				errAndExit(fmt.Errorf("error removing selfsigned certificate: %w", err))
+			}
+		}
+		if err := prepareTLS(config); err != nil {
+			errAndExit(err)
+		}
+	default:
+		args := []string{"renew", "-n", "--cert-name", *config.FQDN}
+		if force {
+			args = append(args, "--force-renewal")
+		}
+
+		certbotCmd := exec.Command("certbot", args...)
+		certbotCmd.Stdout = os.Stdout
+		certbotCmd.Stderr = os.Stderr
+		certbotCmd.Env = os.Environ()
+		if config.TLS != nil && config.TLS.ACME != nil && *config.TLS.ACME.CABundle != "" {
+			certbotCmd.Env = append(certbotCmd.Env, "REQUESTS_CA_BUNDLE="+*config.TLS.ACME.CABundle)
+		}
+		if err := certbotCmd.Run(); err != nil {
+			errAndExit(fmt.Errorf("error running certbot: %w", err))
+		}
+	}
+
+	after := readLeafCertificate(certPath)
+	if after == nil {
+		errAndExit(fmt.Errorf("error reading certificate %q after the renewal", certPath))
+	}
+
+	// certbot and the selfsigned mode keep the certificate if it's not
+	// close to its expiration
+	if !certificateRenewed(before, after) {
+		fmt.Printf("Certificate not renewed as it's not due for renewal yet, it's valid until %s. Use --force to renew it anyway\n", after.NotAfter.Format(time.RFC3339))
+		return
+	}
+
+	if out, err := exec.Command("systemctl", "reload", "nginx").CombinedOutput(); err != nil {
+		errAndExit(fmt.Errorf("error reloading nginx: %w: %s", err, strings.TrimSpace(string(out))))
+	}
+
+	fmt.Printf("Certificate renewed, it's valid until %s. NGINX reloaded\n", after.NotAfter.Format(time.RFC3339))
+}
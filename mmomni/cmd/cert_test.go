@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/certs"
+)
+
+func TestCertExpiryWarning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_cert_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	paths := &certs.SelfSignedPaths{
+		CACert: filepath.Join(dir, "ca.pem"),
+		CAKey:  filepath.Join(dir, "ca.key"),
+		Cert:   filepath.Join(dir, "fullchain.pem"),
+		Key:    filepath.Join(dir, "privkey.pem"),
+	}
+	_, err = certs.EnsureSelfSigned(paths, []string{"mattermost.example.com", "10.0.0.1"}, now)
+	require.NoError(t, err)
+
+	chain, err := certs.ReadCertificates(paths.Cert)
+	require.NoError(t, err)
+	require.Equal(t, []string{"mattermost.example.com", "10.0.0.1"}, certNames(chain[0]))
+
+	t.Run("Should not warn about a certificate far from its expiration", func(t *testing.T) {
+		require.Empty(t, certExpiryWarning(paths.Cert, now))
+	})
+
+	t.Run("Should warn about a certificate that expires soon", func(t *testing.T) {
+		soon := chain[0].NotAfter.Add(-10 * 24 * time.Hour)
+		require.Equal(t, 9, daysRemaining(chain[0], soon.Add(time.Hour)))
+		require.Contains(t, certExpiryWarning(paths.Cert, soon), "expires in")
+	})
+
+	t.Run("Should warn about an expired certificate", func(t *testing.T) {
+		require.Contains(t, certExpiryWarning(paths.Cert, chain[0].NotAfter.Add(48*time.Hour)), "expired on")
+	})
+
+	t.Run("Should detect renewed certificates", func(t *testing.T) {
+		before := readLeafCertificate(paths.Cert)
+		require.NotNil(t, before)
+		require.Nil(t, readLeafCertificate(filepath.Join(dir, "missing.pem")))
+
+		_, err := certs.EnsureSelfSigned(paths, []string{"mattermost.example.com", "10.0.0.1"}, now)
+		require.NoError(t, err)
+		require.False(t, certificateRenewed(before, readLeafCertificate(paths.Cert)), "the existing certificate is kept")
+
+		require.NoError(t, os.Remove(paths.Cert))
+		_, err = certs.EnsureSelfSigned(paths, []string{"mattermost.example.com", "10.0.0.1"}, now)
+		require.NoError(t, err)
+		require.True(t, certificateRenewed(before, readLeafCertificate(paths.Cert)))
+		require.True(t, certificateRenewed(nil, before))
+	})
+
+	t.Run("Should warn about a missing certificate", func(t *testing.T) {
+		require.Contains(t, certExpiryWarning(filepath.Join(dir, "missing.pem"), now), "cannot read certificate")
+	})
+}
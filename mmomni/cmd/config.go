@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func ConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manages the Omnibus configuration file",
+		Long:  "Manages the Mattermost Omnibus configuration file and its history. Every time mmomni modifies the configuration file, the previous version is stored in the /etc/mattermost/history directory",
+	}
+
+	cmd.AddCommand(
+		ConfigHistoryCmd(),
+		ConfigRevertCmd(),
+	)
+
+	return cmd
+}
+
+func ConfigHistoryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "history",
+		Short:   "Lists the previous versions of the configuration",
+		Long:    "Lists the previous versions of the Mattermost Omnibus configuration file, from the most recent to the oldest. The number of each entry can be used with the revert command",
+		Example: `  $ mmomni config history`,
+		Args:    cobra.NoArgs,
+		Run:     configHistoryCmdF,
+	}
+}
+
+func ConfigRevertCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revert <n>",
+		Short: "Reverts the configuration to a previous version",
+		Long:  "Replaces the Mattermost Omnibus configuration file with one of its previous versions. The current configuration is stored in the history, so the revert can be undone",
+		Example: `  # revert to the most recent previous version
+  $ mmomni config revert 1`,
+		Args: cobra.ExactArgs(1),
+		Run:  configRevertCmdF,
+	}
+}
+
+func configHistoryCmdF(_ *cobra.Command, _ []string) {
+	entries, err := model.ConfigHistory(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading configuration history: %w", err))
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No previous configurations found")
+		return
+	}
+
+	for i, entry := range entries {
+		fmt.Printf("%3d  %s  %s\n", i+1, entry.Time.Format("2006-01-02 15:04:05"), entry.Path)
+	}
+}
+
+func configRevertCmdF(_ *cobra.Command, args []string) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		errAndExit(fmt.Errorf("invalid history entry number %q", args[0]))
+	}
+
+	config, err := model.RevertConfig(model.CONFIGPATH, n)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reverting configuration: %w", err))
+	}
+	printConfigChanges(config)
+
+	if err := config.Save(); err != nil {
+		errAndExit(fmt.Errorf("error saving configuration: %w", err))
+	}
+
+	fmt.Printf("Configuration reverted to history entry %d\n", n)
+	fmt.Println("\nPlease run \"mmomni reconfigure\" to apply the reverted configuration")
+}
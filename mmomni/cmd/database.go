@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// postgresClientCommand returns a PostgreSQL client command connected
+// to the database of the configuration through the libpq environment
+// variables
+func postgresClientCommand(config *model.Config, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), config.PostgresClientEnv()...)
+	return cmd
+}
+
+// validateDatabase checks that the files referenced by the database
+// section exist
+func validateDatabase(config *model.Config) error {
+	sslRootCert := *config.DatabaseSettings().SSLRootCert
+	if sslRootCert == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(sslRootCert); err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	return nil
+}
+
+// alterPasswordStatement returns the SQL statement that sets the
+// password of a database user
+func alterPasswordStatement(user, password string) string {
+	return fmt.Sprintf("ALTER ROLE \"%s\" PASSWORD '%s';\n", strings.ReplaceAll(user, `"`, `""`), strings.ReplaceAll(password, "'", "''"))
+}
+
+// alterDatabasePassword sets the password of the database user on an
+// external server, whose roles are not managed by the playbook. The
+// statement is sent through stdin, so the password doesn't show up in
+// the arguments of the process
+func alterDatabasePassword(config *model.Config, password string) error {
+	cmd := postgresClientCommand(config, "psql", "-v", "ON_ERROR_STOP=1", "-q")
+	cmd.Stdin = strings.NewReader(alterPasswordStatement(*config.DBUser, password))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
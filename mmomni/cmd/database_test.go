@@ -0,0 +1,12 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlterPasswordStatement(t *testing.T) {
+	require.Equal(t, "ALTER ROLE \"mmuser\" PASSWORD 'secret';\n", alterPasswordStatement("mmuser", "secret"))
+	require.Equal(t, "ALTER ROLE \"mm\"\"user\" PASSWORD 'it''s';\n", alterPasswordStatement(`mm"user`, "it's"))
+}
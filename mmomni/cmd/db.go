@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// DBUpgradeStatePath stores the upgrade that is pending to be
+// finalized or rolled back, while the old cluster is kept
+const DBUpgradeStatePath = "/etc/mattermost/mmomni.db_upgrade.json"
+
+// postgresUpgradeVersions are the PostgreSQL major versions that the
+// database can be upgraded to
+var postgresUpgradeVersions = []int{14, 15, 16, 17}
+
+// dbUpgradeState describes an upgrade whose old cluster is kept for a
+// rollback
+type dbUpgradeState struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Cluster    string    `json:"cluster"`
+	Port       int       `json:"port"`
+	Method     string    `json:"method"`
+	Backup     string    `json:"backup"`
+	UpgradedAt time.Time `json:"upgraded_at"`
+}
+
+func DbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manages the database",
+		Long:  "Manages the local PostgreSQL database of Mattermost Omnibus",
+	}
+
+	cmd.AddCommand(
+		DbUpgradeCmd(),
+	)
+
+	return cmd
+}
+
+func DbUpgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrades PostgreSQL to a new major version",
+		Long: `Upgrades the local PostgreSQL cluster to a new major version. A database backup is created, the new PostgreSQL version is installed and the cluster is upgraded with pg_upgradecluster, falling back to a dump and restore if pg_upgrade fails. The new cluster takes the port of the old one, so Mattermost connects to it without changes
+
+The old cluster is kept stopped until the upgrade is finalized with --finalize, or rolled back with --rollback, which discards the changes made after the upgrade`,
+		Example: `  $ mmomni db upgrade --to 16
+  $ mmomni db upgrade --finalize
+  $ mmomni db upgrade --rollback`,
+		Args: cobra.NoArgs,
+		Run:  dbUpgradeCmdF,
+	}
+
+	cmd.Flags().Int("to", 0, "The PostgreSQL major version to upgrade to")
+	cmd.Flags().Bool("finalize", false, "Removes the old cluster of a previous upgrade")
+	cmd.Flags().Bool("rollback", false, "Restores the old cluster of a previous upgrade and removes the new one")
+
+	return cmd
+}
+
+// validateUpgradeTarget checks that a cluster version can be upgraded
+// to a major version
+func validateUpgradeTarget(current string, to int) error {
+	supported := false
+	versions := []string{}
+	for _, version := range postgresUpgradeVersions {
+		supported = supported || version == to
+		versions = append(versions, strconv.Itoa(version))
+	}
+	if !supported {
+		return fmt.Errorf("version %d is not supported, it must be one of %s", to, strings.Join(versions, ", "))
+	}
+
+	currentVersion, err := strconv.Atoi(current)
+	if err != nil {
+		return fmt.Errorf("cannot parse the current PostgreSQL version %q: %w", current, err)
+	}
+	if to <= currentVersion {
+		return fmt.Errorf("the database already runs PostgreSQL %d, it can only be upgraded to a newer version", currentVersion)
+	}
+
+	return nil
+}
+
+// findCluster returns the cluster with the given version and name
+func findCluster(clusters []*postgresCluster, version, name string) *postgresCluster {
+	for _, cluster := range clusters {
+		if cluster.Version == version && cluster.Name == name {
+			return cluster
+		}
+	}
+	return nil
+}
+
+// listClusters returns the local PostgreSQL clusters
+func listClusters() ([]*postgresCluster, error) {
+	out, err := exec.Command("pg_lsclusters", "--no-header").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing PostgreSQL clusters: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return parseClusters(string(out))
+}
+
+// readUpgradeState returns the pending upgrade, or nil if there is none
+func readUpgradeState(path string) (*dbUpgradeState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var state dbUpgradeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	return &state, nil
+}
+
+func writeUpgradeState(path string, state *dbUpgradeState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// runLogged runs a command showing its output
+func runLogged(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running %q: %w", strings.Join(append([]string{name}, args...), " "), err)
+	}
+	return nil
+}
+
+// startMattermost starts Mattermost and waits until it responds
+func startMattermost() error {
+	if err := runLogged("systemctl", "start", "mattermost"); err != nil {
+		return err
+	}
+
+	if _, err := waitForMmctl(5*time.Minute, "system", "version"); err != nil {
+		return fmt.Errorf("mattermost is not responding: %w", err)
+	}
+	return nil
+}
+
+// dbUpgrade upgrades the local PostgreSQL cluster to a new major
+// version. The interactions with the system are fields, so the steps
+// of the upgrade can be tested
+type dbUpgrade struct {
+	statePath string
+	configDir string
+	backupDir string
+	mmomni    string
+
+	run       func(name string, args ...string) error
+	clusters  func() ([]*postgresCluster, error)
+	configure func() error
+	start     func() error
+	now       func() time.Time
+}
+
+func newDBUpgrade(config *model.Config) (*dbUpgrade, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbUpgrade{
+		statePath: DBUpgradeStatePath,
+		configDir: PostgresConfigDir,
+		backupDir: model.AUTO_BACKUP_DIR,
+		mmomni:    self,
+		run:       runLogged,
+		clusters:  listClusters,
+		configure: func() error { return configurePostgres(config) },
+		start:     startMattermost,
+		now:       time.Now,
+	}, nil
+}
+
+// dropCluster removes a cluster if it exists, used to clean up the
+// empty cluster created by the package installation or a failed
+// upgrade
+func (u *dbUpgrade) dropCluster(version, name string) error {
+	clusters, err := u.clusters()
+	if err != nil {
+		return err
+	}
+
+	if findCluster(clusters, version, name) == nil {
+		return nil
+	}
+	return u.run("pg_dropcluster", "--stop", version, name)
+}
+
+func dbUpgradeCmdF(cmd *cobra.Command, _ []string) {
+	to, _ := cmd.Flags().GetInt("to")
+	finalize, _ := cmd.Flags().GetBool("finalize")
+	rollback, _ := cmd.Flags().GetBool("rollback")
+
+	actions := 0
+	for _, set := range []bool{to != 0, finalize, rollback} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		errAndExit(fmt.Errorf("exactly one of --to, --finalize or --rollback must be set"))
+	}
+
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if config.ExternalDatabase() {
+		errAndExit(fmt.Errorf("the database is external, so it's not managed by Omnibus"))
+	}
+
+	u, err := newDBUpgrade(config)
+	if err != nil {
+		errAndExit(err)
+	}
+
+	switch {
+	case finalize:
+		err = u.finalize()
+	case rollback:
+		err = u.rollback()
+	default:
+		err = u.upgrade(to)
+	}
+	if err != nil {
+		errAndExit(err)
+	}
+}
+
+func (u *dbUpgrade) upgrade(to int) error {
+	state, err := readUpgradeState(u.statePath)
+	if err != nil {
+		return fmt.Errorf("error reading the pending database upgrade: %w", err)
+	}
+	if state != nil {
+		return fmt.Errorf("the upgrade from PostgreSQL %s to %s is pending, run the command with --finalize or --rollback first", state.From, state.To)
+	}
+
+	clusters, err := u.clusters()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := selectCluster(clusters)
+	if err != nil {
+		return err
+	}
+
+	if err := validateUpgradeTarget(cluster.Version, to); err != nil {
+		return err
+	}
+	toVersion := strconv.Itoa(to)
+
+	if findCluster(clusters, toVersion, cluster.Name) != nil {
+		return fmt.Errorf("the PostgreSQL cluster %s/%s already exists, remove it before upgrading", toVersion, cluster.Name)
+	}
+
+	if err := os.MkdirAll(u.backupDir, 0700); err != nil {
+		return fmt.Errorf("error creating backup path %q: %w", u.backupDir, err)
+	}
+	backupPath := filepath.Join(u.backupDir, fmt.Sprintf("mmobackup_pg%s_%s.tgz", cluster.Version, u.now().Format("20060102_150405")))
+
+	fmt.Printf("Creating a database backup in %q\n", backupPath)
+	if err := u.run(u.mmomni, "backup", "--dbonly", "--output", backupPath); err != nil {
+		return fmt.Errorf("error creating database backup: %w", err)
+	}
+
+	fmt.Printf("Installing PostgreSQL %s\n", toVersion)
+	if err := u.run("apt-get", "install", "-y", "postgresql-"+toVersion); err != nil {
+		return err
+	}
+
+	// the package creates an empty cluster that pg_upgradecluster
+	// needs to create itself
+	if err := u.dropCluster(toVersion, cluster.Name); err != nil {
+		return err
+	}
+
+	if err := u.run("systemctl", "stop", "mattermost"); err != nil {
+		return err
+	}
+
+	method := "upgrade"
+	fmt.Printf("Upgrading cluster %s/%s to PostgreSQL %s\n", cluster.Version, cluster.Name, toVersion)
+	if err := u.run("pg_upgradecluster", "-v", toVersion, "-m", method, cluster.Version, cluster.Name); err != nil {
+		fmt.Printf("pg_upgrade failed (%s), falling back to a dump and restore\n", err)
+		if err := u.dropCluster(toVersion, cluster.Name); err != nil {
+			return fmt.Errorf("error removing the partially upgraded cluster, Mattermost is stopped: %w", err)
+		}
+
+		method = "dump"
+		if err := u.run("pg_upgradecluster", "-v", toVersion, "-m", method, cluster.Version, cluster.Name); err != nil {
+			upgradeErr := fmt.Errorf("error upgrading the database, the cluster %s/%s is unchanged and the backup is in %q: %w", cluster.Version, cluster.Name, backupPath, err)
+			if dropErr := u.dropCluster(toVersion, cluster.Name); dropErr != nil {
+				return fmt.Errorf("%w. The partially upgraded cluster could not be removed, and Mattermost is stopped: %s", upgradeErr, dropErr)
+			}
+			if startErr := u.start(); startErr != nil {
+				return fmt.Errorf("%w. Mattermost could not be started again: %s", upgradeErr, startErr)
+			}
+			return upgradeErr
+		}
+	}
+
+	state = &dbUpgradeState{
+		From:       cluster.Version,
+		To:         toVersion,
+		Cluster:    cluster.Name,
+		Port:       cluster.Port,
+		Method:     method,
+		Backup:     backupPath,
+		UpgradedAt: u.now(),
+	}
+	if err := writeUpgradeState(u.statePath, state); err != nil {
+		return fmt.Errorf("error saving the database upgrade state, Mattermost is stopped: %w", err)
+	}
+
+	rollbackHint := fmt.Sprintf("Mattermost is stopped, run the command with --rollback to restore PostgreSQL %s", cluster.Version)
+
+	clusters, err = u.clusters()
+	if err != nil {
+		return fmt.Errorf("%w. %s", err, rollbackHint)
+	}
+	upgraded := findCluster(clusters, toVersion, cluster.Name)
+	if upgraded == nil || upgraded.Port != cluster.Port || upgraded.Status != "online" {
+		return fmt.Errorf("the cluster %s/%s is not online on port %d after the upgrade. %s", toVersion, cluster.Name, cluster.Port, rollbackHint)
+	}
+
+	// the postgres drop-in is written to the new cluster
+	if err := u.configure(); err != nil {
+		return fmt.Errorf("error configuring PostgreSQL: %w. %s", err, rollbackHint)
+	}
+
+	if err := u.start(); err != nil {
+		return fmt.Errorf("%w. Run the command with --rollback to restore PostgreSQL %s", err, cluster.Version)
+	}
+
+	fmt.Printf("\nThe database was upgraded to PostgreSQL %s. The old cluster %s/%s is kept stopped until the upgrade is finalized with \"mmomni db upgrade --finalize\", or rolled back with \"mmomni db upgrade --rollback\"\n", toVersion, cluster.Version, cluster.Name)
+	return nil
+}
+
+func (u *dbUpgrade) finalize() error {
+	state, err := readUpgradeState(u.statePath)
+	if err != nil {
+		return fmt.Errorf("error reading the pending database upgrade: %w", err)
+	}
+	if state == nil {
+		return fmt.Errorf("there is no database upgrade to finalize")
+	}
+
+	if err := u.dropCluster(state.From, state.Cluster); err != nil {
+		return fmt.Errorf("error removing the old cluster: %w", err)
+	}
+
+	if err := os.Remove(u.statePath); err != nil {
+		return err
+	}
+
+	fmt.Printf("The database upgrade to PostgreSQL %s is finalized. The postgresql-%s package can now be removed\n", state.To, state.From)
+	return nil
+}
+
+func (u *dbUpgrade) rollback() error {
+	state, err := readUpgradeState(u.statePath)
+	if err != nil {
+		return fmt.Errorf("error reading the pending database upgrade: %w", err)
+	}
+	if state == nil {
+		return fmt.Errorf("there is no database upgrade to roll back")
+	}
+
+	port := state.Port
+	if port == 0 {
+		port = PostgresDefaultPort
+	}
+
+	if err := u.run("systemctl", "stop", "mattermost"); err != nil {
+		return err
+	}
+
+	if err := u.dropCluster(state.To, state.Cluster); err != nil {
+		return fmt.Errorf("error removing the new cluster: %w", err)
+	}
+
+	// pg_upgradecluster moves the old cluster to another port and
+	// disables its automatic start
+	if err := u.run("pg_conftool", state.From, state.Cluster, "set", "port", strconv.Itoa(port)); err != nil {
+		return err
+	}
+	startConf := filepath.Join(u.configDir, state.From, state.Cluster, "start.conf")
+	if err := ioutil.WriteFile(startConf, []byte("auto\n"), 0644); err != nil {
+		return fmt.Errorf("error enabling the automatic start of the old cluster: %w", err)
+	}
+	if err := u.run("pg_ctlcluster", state.From, state.Cluster, "start"); err != nil {
+		return err
+	}
+
+	if err := u.configure(); err != nil {
+		return fmt.Errorf("error configuring PostgreSQL: %w", err)
+	}
+
+	if err := os.Remove(u.statePath); err != nil {
+		return err
+	}
+
+	if err := u.start(); err != nil {
+		return err
+	}
+
+	fmt.Printf("The database was rolled back to PostgreSQL %s, changes made after the upgrade were discarded\n", state.From)
+	return nil
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateUpgradeTarget(t *testing.T) {
+	require.NoError(t, validateUpgradeTarget("13", 16))
+	require.NoError(t, validateUpgradeTarget("16", 17))
+	require.Error(t, validateUpgradeTarget("13", 12))
+	require.Error(t, validateUpgradeTarget("16", 16))
+	require.Error(t, validateUpgradeTarget("16", 15))
+	require.Error(t, validateUpgradeTarget("13", 99))
+	require.Error(t, validateUpgradeTarget("unknown", 16))
+}
+
+func TestFindCluster(t *testing.T) {
+	clusters := []*postgresCluster{
+		{Version: "13", Name: "main", Port: 5433, Status: "down"},
+		{Version: "16", Name: "main", Port: 5432, Status: "online"},
+	}
+
+	require.Equal(t, clusters[0], findCluster(clusters, "13", "main"))
+	require.Equal(t, clusters[1], findCluster(clusters, "16", "main"))
+	require.Nil(t, findCluster(clusters, "16", "other"))
+	require.Nil(t, findCluster(clusters, "17", "main"))
+}
+
+func TestUpgradeState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni-db")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mmomni.db_upgrade.json")
+
+	state, err := readUpgradeState(path)
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	expected := &dbUpgradeState{
+		From:       "13",
+		To:         "16",
+		Cluster:    "main",
+		Port:       5432,
+		Method:     "upgrade",
+		Backup:     "/var/opt/mattermost/backups/mmobackup_pg13.tgz",
+		UpgradedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, writeUpgradeState(path, expected))
+
+	state, err = readUpgradeState(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, state)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	_, err = readUpgradeState(path)
+	require.Error(t, err)
+}
+
+// fakePostgres simulates the PostgreSQL cluster tools, applying the
+// commands run by a dbUpgrade to a list of clusters
+type fakePostgres struct {
+	clusters []*postgresCluster
+	commands []string
+	// fail contains the commands that return an error
+	fail map[string]bool
+}
+
+func (f *fakePostgres) run(name string, args ...string) error {
+	command := strings.Join(append([]string{filepath.Base(name)}, args...), " ")
+	f.commands = append(f.commands, command)
+	if f.fail[command] {
+		return fmt.Errorf("%s failed", command)
+	}
+
+	switch filepath.Base(name) {
+	case "apt-get":
+		version := strings.TrimPrefix(args[2], "postgresql-")
+		f.clusters = append(f.clusters, &postgresCluster{Version: version, Name: "main", Port: 5433, Status: "online"})
+	case "pg_dropcluster":
+		clusters := []*postgresCluster{}
+		for _, c := range f.clusters {
+			if c.Version != args[1] || c.Name != args[2] {
+				clusters = append(clusters, c)
+			}
+		}
+		f.clusters = clusters
+	case "pg_upgradecluster":
+		old := findCluster(f.clusters, args[4], args[5])
+		f.clusters = append(f.clusters, &postgresCluster{Version: args[1], Name: args[5], Port: old.Port, Status: "online"})
+		old.Port, old.Status = 5434, "down"
+	case "pg_conftool":
+		findCluster(f.clusters, args[0], args[1]).Port, _ = strconv.Atoi(args[4])
+	case "pg_ctlcluster":
+		findCluster(f.clusters, args[0], args[1]).Status = "online"
+	}
+	return nil
+}
+
+// list returns copies of the clusters, as pg_lsclusters does
+func (f *fakePostgres) list() ([]*postgresCluster, error) {
+	clusters := make([]*postgresCluster, len(f.clusters))
+	for i, c := range f.clusters {
+		copied := *c
+		clusters[i] = &copied
+	}
+	return clusters, nil
+}
+
+func newTestDBUpgrade(t *testing.T, f *fakePostgres) (*dbUpgrade, *[]string, func()) {
+	dir, err := ioutil.TempDir("", "mmomni-db")
+	require.NoError(t, err)
+
+	events := []string{}
+	u := &dbUpgrade{
+		statePath: filepath.Join(dir, "mmomni.db_upgrade.json"),
+		configDir: filepath.Join(dir, "postgresql"),
+		backupDir: filepath.Join(dir, "backups"),
+		mmomni:    "mmomni",
+		run:       f.run,
+		clusters:  f.list,
+		configure: func() error { events = append(events, "configure"); return nil },
+		start:     func() error { events = append(events, "start"); return nil },
+		now:       func() time.Time { return time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC) },
+	}
+	return u, &events, func() { os.RemoveAll(dir) }
+}
+
+func TestDBUpgrade(t *testing.T) {
+	t.Run("upgrade, rollback and upgrade again", func(t *testing.T) {
+		f := &fakePostgres{clusters: []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}}}
+		u, events, cleanup := newTestDBUpgrade(t, f)
+		defer cleanup()
+
+		require.NoError(t, u.upgrade(16))
+		require.Equal(t, []string{
+			"mmomni backup --dbonly --output " + filepath.Join(u.backupDir, "mmobackup_pg13_20240501_100000.tgz"),
+			"apt-get install -y postgresql-16",
+			"pg_dropcluster --stop 16 main",
+			"systemctl stop mattermost",
+			"pg_upgradecluster -v 16 -m upgrade 13 main",
+		}, f.commands)
+		require.Equal(t, []string{"configure", "start"}, *events)
+
+		state, err := readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.Equal(t, "13", state.From)
+		require.Equal(t, "16", state.To)
+		require.Equal(t, 5432, state.Port)
+		require.Equal(t, "upgrade", state.Method)
+
+		err = u.upgrade(17)
+		require.Error(t, err, "a pending upgrade should block new ones")
+		require.Contains(t, err.Error(), "is pending")
+
+		require.NoError(t, os.MkdirAll(filepath.Join(u.configDir, "13", "main"), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(u.configDir, "13", "main", "start.conf"), []byte("manual\n"), 0644))
+
+		f.commands = []string{}
+		*events = []string{}
+		require.NoError(t, u.rollback())
+		require.Equal(t, []string{
+			"systemctl stop mattermost",
+			"pg_dropcluster --stop 16 main",
+			"pg_conftool 13 main set port 5432",
+			"pg_ctlcluster 13 main start",
+		}, f.commands)
+		require.Equal(t, []string{"configure", "start"}, *events)
+		require.Equal(t, []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}}, f.clusters)
+
+		startConf, err := ioutil.ReadFile(filepath.Join(u.configDir, "13", "main", "start.conf"))
+		require.NoError(t, err)
+		require.Equal(t, "auto\n", string(startConf))
+
+		state, err = readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.Nil(t, state)
+
+		require.NoError(t, u.upgrade(16))
+	})
+
+	t.Run("finalize removes the old cluster", func(t *testing.T) {
+		f := &fakePostgres{clusters: []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}}}
+		u, _, cleanup := newTestDBUpgrade(t, f)
+		defer cleanup()
+
+		require.Error(t, u.finalize(), "there is nothing to finalize")
+		require.Error(t, u.rollback(), "there is nothing to roll back")
+
+		require.NoError(t, u.upgrade(16))
+		f.commands = []string{}
+		require.NoError(t, u.finalize())
+		require.Equal(t, []string{"pg_dropcluster --stop 13 main"}, f.commands)
+		require.Equal(t, []*postgresCluster{{Version: "16", Name: "main", Port: 5432, Status: "online"}}, f.clusters)
+
+		state, err := readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.Nil(t, state)
+	})
+
+	t.Run("pg_upgrade failures fall back to a dump", func(t *testing.T) {
+		f := &fakePostgres{
+			clusters: []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}},
+			fail:     map[string]bool{"pg_upgradecluster -v 16 -m upgrade 13 main": true},
+		}
+		u, _, cleanup := newTestDBUpgrade(t, f)
+		defer cleanup()
+
+		require.NoError(t, u.upgrade(16))
+		require.Contains(t, f.commands, "pg_upgradecluster -v 16 -m dump 13 main")
+
+		state, err := readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.Equal(t, "dump", state.Method)
+	})
+
+	t.Run("a failed dump restarts Mattermost and keeps no state", func(t *testing.T) {
+		f := &fakePostgres{
+			clusters: []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}},
+			fail: map[string]bool{
+				"pg_upgradecluster -v 16 -m upgrade 13 main": true,
+				"pg_upgradecluster -v 16 -m dump 13 main":    true,
+			},
+		}
+		u, events, cleanup := newTestDBUpgrade(t, f)
+		defer cleanup()
+
+		err := u.upgrade(16)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "is unchanged")
+		require.Equal(t, []string{"start"}, *events)
+
+		u.start = func() error { return fmt.Errorf("mattermost is not responding") }
+		err = u.upgrade(16)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Mattermost could not be started again: mattermost is not responding")
+
+		state, err := readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.Nil(t, state)
+	})
+
+	t.Run("an upgraded cluster that is not online reports that Mattermost is stopped", func(t *testing.T) {
+		f := &fakePostgres{clusters: []*postgresCluster{{Version: "13", Name: "main", Port: 5432, Status: "online"}}}
+		u, events, cleanup := newTestDBUpgrade(t, f)
+		defer cleanup()
+
+		run := u.run
+		u.run = func(name string, args ...string) error {
+			err := run(name, args...)
+			if name == "pg_upgradecluster" {
+				findCluster(f.clusters, "16", "main").Status = "down"
+			}
+			return err
+		}
+
+		err := u.upgrade(16)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Mattermost is stopped, run the command with --rollback")
+		require.Empty(t, *events)
+
+		state, err := readUpgradeState(u.statePath)
+		require.NoError(t, err)
+		require.NotNil(t, state, "the state is kept so the upgrade can be rolled back")
+	})
+}
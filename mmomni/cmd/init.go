@@ -23,7 +23,11 @@ This command is expected to be called from the package installation scripts, not
 
   # https can be disabled if the server is not reachable from the internet,
   # so the SSL certificate cannot be generated
-  $ mmomni init --fqdn my.domain.com --email contact@example.com --https false`,
+  $ mmomni init --fqdn my.domain.com --email contact@example.com --https false
+
+  # the first admin user and team can be created by reconfigure, so
+  # the server is never exposed without an admin
+  $ mmomni init --fqdn my.domain.com --email contact@example.com --admin-username admin --admin-email admin@example.com --team-name myteam`,
 		Args: cobra.NoArgs,
 		Run:  initCmdF,
 	}
@@ -34,6 +38,11 @@ This command is expected to be called from the package installation scripts, not
 	_ = cmd.MarkFlagRequired("email")
 	cmd.Flags().Bool("https", true, "Enable to configure the SSL certificate")
 	_ = viper.BindPFlag("https", cmd.Flags().Lookup("https"))
+	cmd.Flags().String("admin-username", "", "Username of the admin user created on the first reconfigure")
+	cmd.Flags().String("admin-email", "", "Email address of the admin user created on the first reconfigure")
+	cmd.Flags().String("admin-password-file", "", "File containing the password of the admin user. If it doesn't exist, a random password is written to it")
+	cmd.Flags().String("team-name", "", "Name of the team created on the first reconfigure")
+	cmd.Flags().String("team-display-name", "", "Display name of the team created on the first reconfigure")
 
 	return cmd
 }
@@ -50,6 +59,7 @@ func initCmdF(cmd *cobra.Command, _ []string) {
 		if err != nil {
 			errAndExit(fmt.Errorf("error reading configuration file in %q: %w", model.CONFIGPATH, err))
 		}
+		printConfigChanges(config)
 	} else {
 		config = &model.Config{Path: model.CONFIGPATH}
 		config.SetDefaults()
@@ -58,10 +68,20 @@ func initCmdF(cmd *cobra.Command, _ []string) {
 	}
 
 	config.FQDN = model.NewString(ParseFQDN(fqdn))
+	// postinst runs init with the bare fqdn on every package upgrade,
+	// so the subpath is only updated if the fqdn includes one
+	if subpath := ParseSubpath(fqdn); subpath != "" {
+		config.Subpath = model.NewString(subpath)
+	}
 	config.Email = model.NewString(email)
 	config.EnableLocalMode = model.NewBool(true)
 	config.ClientMaxBodySize = model.NewString("50M")
 
+	if cmd.Flags().Changed("admin-username") {
+		config.Bootstrap = bootstrapFromFlags(cmd)
+		config.SetDefaults()
+	}
+
 	// when initializing the configuration, we save it without
 	// validating it, so the file gets written to disk and the user
 	// can change it later
@@ -71,3 +91,26 @@ func initCmdF(cmd *cobra.Command, _ []string) {
 
 	fmt.Printf("config file %q successfully saved\n", model.CONFIGPATH)
 }
+
+// bootstrapFromFlags creates the bootstrap section from the init
+// flags, leaving the admin password file unset if the flag is empty so
+// it gets its default value
+func bootstrapFromFlags(cmd *cobra.Command) *model.BootstrapConfig {
+	adminUsername, _ := cmd.Flags().GetString("admin-username")
+	adminEmail, _ := cmd.Flags().GetString("admin-email")
+	adminPasswordFile, _ := cmd.Flags().GetString("admin-password-file")
+	teamName, _ := cmd.Flags().GetString("team-name")
+	teamDisplayName, _ := cmd.Flags().GetString("team-display-name")
+
+	bootstrap := &model.BootstrapConfig{
+		AdminUsername:   model.NewString(adminUsername),
+		AdminEmail:      model.NewString(adminEmail),
+		TeamName:        model.NewString(teamName),
+		TeamDisplayName: model.NewString(teamDisplayName),
+	}
+	if adminPasswordFile != "" {
+		bootstrap.AdminPasswordFile = model.NewString(adminPasswordFile)
+	}
+
+	return bootstrap
+}
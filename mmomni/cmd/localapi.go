@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// LocalModeSocketPath is the default location of the local mode
+// socket of the Mattermost server
+const LocalModeSocketPath = "/var/tmp/mattermost_local.socket"
+
+// localModeSocket is the socket used by localAPIRequest
+var localModeSocket = LocalModeSocketPath
+
+// localAPIRequest sends a request to the Mattermost API through the
+// local mode socket and decodes the JSON response into result if it's
+// not nil. It's used instead of mmctl for requests that carry secrets,
+// so they don't show up in the arguments of a process
+func localAPIRequest(method, path string, body, result interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, "http://_"+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", localModeSocket)
+			},
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var appErr struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(respBody, &appErr); err == nil && appErr.Message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, appErr.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("%s %s: cannot decode response: %w", method, path, err)
+		}
+	}
+
+	return nil
+}
+
+// localAPIUserID returns the id of a user, identified by username or
+// email
+func localAPIUserID(user string) (string, error) {
+	path := "/api/v4/users/username/" + url.PathEscape(user)
+	if strings.Contains(user, "@") {
+		path = "/api/v4/users/email/" + url.PathEscape(user)
+	}
+
+	var u struct {
+		ID string `json:"id"`
+	}
+	if err := localAPIRequest(http.MethodGet, path, nil, &u); err != nil {
+		return "", err
+	}
+	return u.ID, nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveLocalAPI serves handler on a temporary local mode socket
+func serveLocalAPI(t *testing.T, handler http.HandlerFunc) func() {
+	dir, err := ioutil.TempDir("", "mmomni_")
+	require.NoError(t, err)
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "mattermost_local.socket"))
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+
+	previous := localModeSocket
+	localModeSocket = listener.Addr().String()
+	return func() {
+		localModeSocket = previous
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLocalAPI(t *testing.T) {
+	requests := []string{}
+	bodies := map[string]map[string]string{}
+	cleanup := serveLocalAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		body := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies[r.Method+" "+r.URL.Path] = body
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/v4/users":
+			_, _ = w.Write([]byte(`{"id": "userid"}`))
+		case "GET /api/v4/users/username/admin", "GET /api/v4/users/email/admin@example.com":
+			_, _ = w.Write([]byte(`{"id": "userid"}`))
+		case "PUT /api/v4/users/userid/roles", "PUT /api/v4/users/userid/password":
+			_, _ = w.Write([]byte(`{"status": "OK"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Unable to find the user."}`))
+		}
+	})
+	defer cleanup()
+
+	t.Run("Should create a system admin", func(t *testing.T) {
+		requests = []string{}
+		require.NoError(t, createAdminUser("admin", "admin@example.com", "secret"))
+		require.Equal(t, []string{"POST /api/v4/users", "PUT /api/v4/users/userid/roles"}, requests)
+		require.Equal(t, "secret", bodies["POST /api/v4/users"]["password"])
+		require.Equal(t, "system_user system_admin", bodies["PUT /api/v4/users/userid/roles"]["roles"])
+	})
+
+	t.Run("Should grant the system admin role to an existing user", func(t *testing.T) {
+		requests = []string{}
+		require.NoError(t, grantSystemAdmin("admin"))
+		require.Equal(t, []string{"GET /api/v4/users/username/admin", "PUT /api/v4/users/userid/roles"}, requests)
+		require.Equal(t, "system_user system_admin", bodies["PUT /api/v4/users/userid/roles"]["roles"])
+	})
+
+	t.Run("Should reset the password by username or email", func(t *testing.T) {
+		requests = []string{}
+		require.NoError(t, resetPassword("admin", "new-secret"))
+		require.NoError(t, resetPassword("admin@example.com", "new-secret"))
+		require.Equal(t, []string{
+			"GET /api/v4/users/username/admin",
+			"PUT /api/v4/users/userid/password",
+			"GET /api/v4/users/email/admin@example.com",
+			"PUT /api/v4/users/userid/password",
+		}, requests)
+		require.Equal(t, "new-secret", bodies["PUT /api/v4/users/userid/password"]["new_password"])
+	})
+
+	t.Run("Should return the API error message", func(t *testing.T) {
+		err := resetPassword("unknown", "new-secret")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Unable to find the user.")
+	})
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func MmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mm <mmctl arguments>",
+		Short: "Runs mmctl against the local server",
+		Long: `Runs the mmctl command shipped with Mattermost as the mattermost user, connected to the server through its local mode socket, so no authentication is needed. All the arguments are passed to mmctl
+
+The local mode needs to be enabled with the enable_local_mode property of /etc/mattermost/mmomni.yml`,
+		Example: `  $ mmomni mm user list
+  $ mmomni mm team create --name myteam --display-name "My Team"
+  $ mmomni mm --help`,
+		DisableFlagParsing: true,
+		Run:                mmCmdF,
+	}
+}
+
+func mmCmdF(_ *cobra.Command, args []string) {
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if !*config.EnableLocalMode {
+		errAndExit(fmt.Errorf("enable_local_mode needs to be true in %q to run mmctl", model.CONFIGPATH))
+	}
+
+	mmctlCmd, err := mmctlCommand(args...)
+	if err != nil {
+		errAndExit(err)
+	}
+	mmctlCmd.Stdin = os.Stdin
+	mmctlCmd.Stdout = os.Stdout
+	mmctlCmd.Stderr = os.Stderr
+
+	if err := mmctlCmd.Run(); err != nil {
+		// mmctl already printed its errors, so only its exit code is
+		// passed through
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		errAndExit(fmt.Errorf("error running mmctl: %w", err))
+	}
+}
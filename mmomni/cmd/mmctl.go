@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	MattermostUser = "mattermost"
+	MmctlPath      = "/opt/mattermost/bin/mmctl"
+)
+
+// systemUser contains the ids of a system user, used to run commands
+// on its behalf
+type systemUser struct {
+	Uid  uint32
+	Gid  uint32
+	Home string
+}
+
+func lookupSystemUser(name string) (*systemUser, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q for user %q: %w", u.Uid, name, err)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for user %q: %w", u.Gid, name, err)
+	}
+
+	return &systemUser{Uid: uint32(uid), Gid: uint32(gid), Home: u.HomeDir}, nil
+}
+
+// mmctlCommand returns a command that runs mmctl as the mattermost
+// user against the local mode socket of the server
+func mmctlCommand(args ...string) (*exec.Cmd, error) {
+	mmUser, err := lookupSystemUser(MattermostUser)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up the %q user: %w", MattermostUser, err)
+	}
+
+	cmd := exec.Command(MmctlPath, append([]string{"--local"}, args...)...)
+	cmd.Dir = "/opt/mattermost"
+	cmd.Env = append(os.Environ(), "HOME="+mmUser.Home)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: mmUser.Uid, Gid: mmUser.Gid},
+	}
+
+	return cmd, nil
+}
+
+// runMmctl runs mmctl in local mode and returns its standard output.
+// If the command fails, its error output is included in the error
+func runMmctl(args ...string) ([]byte, error) {
+	cmd, err := mmctlCommand(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("mmctl %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return nil, fmt.Errorf("mmctl %s: %w", strings.Join(args, " "), err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// waitForMmctl runs mmctl until it succeeds or the timeout expires,
+// to wait for a server that is starting to accept connections on its
+// local mode socket
+func waitForMmctl(timeout time.Duration, args ...string) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := runMmctl(args...)
+		if err == nil || time.Now().After(deadline) {
+			return out, err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// stageFileForMattermost copies a file into a temporal directory owned
+// by the mattermost user, so mmctl can read it regardless of the
+// permissions of the original file. The returned function removes the
+// temporal directory
+func stageFileForMattermost(path string) (string, func(), error) {
+	mmUser, err := lookupSystemUser(MattermostUser)
+	if err != nil {
+		return "", nil, fmt.Errorf("error looking up the %q user: %w", MattermostUser, err)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	dir, err := ioutil.TempDir(os.TempDir(), "mmomni_")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	stagedPath := filepath.Join(dir, filepath.Base(path))
+	dst, err := os.OpenFile(stagedPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		cleanup()
+		return "", nil, err
+	}
+
+	if err := dst.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	for _, p := range []string{dir, stagedPath} {
+		if err := os.Chown(p, int(mmUser.Uid), int(mmUser.Gid)); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	return stagedPath, cleanup, nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/certs"
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// validateAccessControl checks that the client CA bundle of the
+// access_control section can be used by NGINX
+func validateAccessControl(config *model.Config) error {
+	if config.AccessControl == nil || *config.AccessControl.ClientCA == "" {
+		return nil
+	}
+
+	if err := certs.ValidateClientCA(*config.AccessControl.ClientCA, time.Now()); err != nil {
+		return fmt.Errorf("invalid access_control client_ca: %w", err)
+	}
+	return nil
+}
+
+// nginxVars returns the playbook variables that customize the stock
+// NGINX template
+func nginxVars(config *model.Config) map[string]interface{} {
+	settings := config.NginxSettings()
+
+	serverDirectives := []string{}
+	for _, directive := range settings.ServerDirectives {
+		serverDirectives = append(serverDirectives, strings.TrimSpace(directive))
+	}
+
+	locations := []map[string]interface{}{}
+	for _, location := range settings.Locations {
+		directives := []string{}
+		for _, directive := range location.Directives {
+			directives = append(directives, strings.TrimSpace(directive))
+		}
+		locations = append(locations, map[string]interface{}{
+			"path":       strings.TrimSpace(*location.Path),
+			"directives": directives,
+		})
+	}
+
+	rateLimits := config.NginxRateLimitDirectives()
+	listen := config.NginxListenDirectives()
+
+	return map[string]interface{}{
+		"nginx_server_directives":      serverDirectives,
+		"nginx_locations":              locations,
+		"nginx_include_dir":            *settings.IncludeDir,
+		"nginx_proxy_connect_timeout":  *settings.ProxyConnectTimeout,
+		"nginx_proxy_send_timeout":     *settings.ProxySendTimeout,
+		"nginx_proxy_read_timeout":     *settings.ProxyReadTimeout,
+		"nginx_websocket_read_timeout": *settings.WebsocketReadTimeout,
+		"nginx_cache_max_size":         *settings.CacheMaxSize,
+		"nginx_rate_limit_http":        rateLimits.HTTP,
+		"nginx_rate_limit_server":      rateLimits.Server,
+		"nginx_rate_limit_root":        rateLimits.Root,
+		"nginx_rate_limit_websocket":   rateLimits.Websocket,
+		"nginx_access_control":         config.NginxAccessControlDirectives(),
+		"nginx_forwarded_proto":        config.NginxForwardedProto(),
+		"nginx_listen": map[string][]string{
+			"http_default": listen.HTTPDefault,
+			"http":         listen.HTTP,
+			"https":        listen.HTTPS,
+		},
+		"mattermost_upstream": config.MattermostUpstream(),
+		"nginx_subpath_regex": config.NginxSubpathRegex(),
+	}
+}
@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+const (
+	pluginActionInstall = "install"
+	pluginActionEnable  = "enable"
+	pluginActionDisable = "disable"
+)
+
+func PluginCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Manages the Mattermost plugins",
+		Long: `Manages the Mattermost plugins through the local mode of the server, which needs to be enabled with the enable_local_mode property of /etc/mattermost/mmomni.yml
+
+Plugins can also be declared in the plugins section of the configuration file. When the section is set, "mmomni reconfigure" installs and enables the listed plugins and disables the rest, reverting the changes made with these commands`,
+	}
+
+	cmd.AddCommand(
+		PluginDisableCmd(),
+		PluginEnableCmd(),
+		PluginInstallCmd(),
+		PluginListCmd(),
+		PluginRemoveCmd(),
+	)
+
+	return cmd
+}
+
+func PluginInstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "install <file|url>",
+		Short: "Installs a plugin",
+		Long:  "Installs a plugin from a bundle file or from an http or https URL. Installing plugins requires enable_plugin_uploads to be true. The plugin is not enabled after being installed",
+		Example: `  $ mmomni plugin install /tmp/com.github.matterpoll.matterpoll.tar.gz
+  $ mmomni plugin install https://github.com/matterpoll/matterpoll/releases/download/v1.4.0/com.github.matterpoll.matterpoll-1.4.0.tar.gz`,
+		Args: cobra.ExactArgs(1),
+		Run:  pluginInstallCmdF,
+	}
+}
+
+func PluginListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "Lists the installed plugins",
+		Example: `  $ mmomni plugin list`,
+		Args:    cobra.NoArgs,
+		Run:     pluginListCmdF,
+	}
+}
+
+func PluginEnableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "enable <id>",
+		Short:   "Enables a plugin",
+		Example: `  $ mmomni plugin enable com.github.matterpoll.matterpoll`,
+		Args:    cobra.ExactArgs(1),
+		Run:     pluginEnableCmdF,
+	}
+}
+
+func PluginDisableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "disable <id>",
+		Short:   "Disables a plugin",
+		Example: `  $ mmomni plugin disable com.github.matterpoll.matterpoll`,
+		Args:    cobra.ExactArgs(1),
+		Run:     pluginDisableCmdF,
+	}
+}
+
+func PluginRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <id>",
+		Short:   "Removes a plugin",
+		Example: `  $ mmomni plugin remove com.github.matterpoll.matterpoll`,
+		Args:    cobra.ExactArgs(1),
+		Run:     pluginRemoveCmdF,
+	}
+}
+
+// pluginInfo contains the fields of the plugin manifests returned by
+// mmctl that mmomni uses
+type pluginInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type pluginList struct {
+	Active   []*pluginInfo `json:"active"`
+	Inactive []*pluginInfo `json:"inactive"`
+}
+
+// parsePluginList parses the JSON output of "mmctl plugin list", which
+// depending on the mmctl version is either an object or an array
+// containing it
+func parsePluginList(out []byte) (*pluginList, error) {
+	var list pluginList
+	if err := json.Unmarshal(out, &list); err == nil {
+		return &list, nil
+	}
+
+	var lists []*pluginList
+	if err := json.Unmarshal(out, &lists); err != nil {
+		return nil, fmt.Errorf("cannot parse plugin list: %w", err)
+	}
+	if len(lists) != 1 {
+		return nil, fmt.Errorf("cannot parse plugin list: expected one element, got %d", len(lists))
+	}
+	return lists[0], nil
+}
+
+func listPlugins() (*pluginList, error) {
+	out, err := runMmctl("plugin", "list", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	return parsePluginList(out)
+}
+
+// installPlugin installs a plugin from a URL or a local file
+func installPlugin(source string) error {
+	if model.IsPluginURL(source) {
+		_, err := runMmctl("plugin", "install-url", source)
+		return err
+	}
+
+	stagedPath, cleanup, err := stageFileForMattermost(source)
+	if err != nil {
+		return fmt.Errorf("error reading plugin bundle %q: %w", source, err)
+	}
+	defer cleanup()
+
+	_, err = runMmctl("plugin", "add", stagedPath)
+	return err
+}
+
+// readPluginConfig reads the configuration and checks that the local
+// mode is enabled, as all plugin operations depend on it
+func readPluginConfig() *model.Config {
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if !*config.EnableLocalMode {
+		errAndExit(fmt.Errorf("enable_local_mode needs to be true in %q to manage plugins", model.CONFIGPATH))
+	}
+
+	return config
+}
+
+func pluginInstallCmdF(_ *cobra.Command, args []string) {
+	config := readPluginConfig()
+	if !*config.EnablePluginUploads {
+		errAndExit(fmt.Errorf("enable_plugin_uploads needs to be true in %q to install plugins", model.CONFIGPATH))
+	}
+
+	if err := installPlugin(args[0]); err != nil {
+		errAndExit(fmt.Errorf("error installing plugin: %w", err))
+	}
+
+	fmt.Printf("Plugin installed from %q, run \"mmomni plugin enable <id>\" to enable it\n", args[0])
+}
+
+func pluginListCmdF(_ *cobra.Command, _ []string) {
+	readPluginConfig()
+
+	list, err := listPlugins()
+	if err != nil {
+		errAndExit(fmt.Errorf("error listing plugins: %w", err))
+	}
+
+	fmt.Println("Enabled plugins:")
+	for _, plugin := range list.Active {
+		fmt.Printf("  %s: %s, version %s\n", plugin.ID, plugin.Name, plugin.Version)
+	}
+
+	fmt.Println("Disabled plugins:")
+	for _, plugin := range list.Inactive {
+		fmt.Printf("  %s: %s, version %s\n", plugin.ID, plugin.Name, plugin.Version)
+	}
+}
+
+func pluginEnableCmdF(_ *cobra.Command, args []string) {
+	readPluginConfig()
+
+	if _, err := runMmctl("plugin", "enable", args[0]); err != nil {
+		errAndExit(fmt.Errorf("error enabling plugin %q: %w", args[0], err))
+	}
+
+	fmt.Printf("Plugin %q enabled\n", args[0])
+}
+
+func pluginDisableCmdF(_ *cobra.Command, args []string) {
+	readPluginConfig()
+
+	if _, err := runMmctl("plugin", "disable", args[0]); err != nil {
+		errAndExit(fmt.Errorf("error disabling plugin %q: %w", args[0], err))
+	}
+
+	fmt.Printf("Plugin %q disabled\n", args[0])
+}
+
+func pluginRemoveCmdF(_ *cobra.Command, args []string) {
+	readPluginConfig()
+
+	if _, err := runMmctl("plugin", "delete", args[0]); err != nil {
+		errAndExit(fmt.Errorf("error removing plugin %q: %w", args[0], err))
+	}
+
+	fmt.Printf("Plugin %q removed\n", args[0])
+}
+
+// pluginAction is a change needed to converge the installed plugins
+// to the plugins section of the configuration
+type pluginAction struct {
+	Action string
+	ID     string
+	Source string
+}
+
+func (a *pluginAction) String() string {
+	if a.Action == pluginActionInstall {
+		return fmt.Sprintf("%s %s from %s", a.Action, a.ID, a.Source)
+	}
+	return fmt.Sprintf("%s %s", a.Action, a.ID)
+}
+
+// planPluginActions returns the actions needed to install and enable
+// the configured plugins and to disable the ones that are not listed
+func planPluginActions(plugins []*model.PluginConfig, installed *pluginList) ([]*pluginAction, error) {
+	active := map[string]bool{}
+	for _, plugin := range installed.Active {
+		active[plugin.ID] = true
+	}
+
+	inactive := map[string]bool{}
+	for _, plugin := range installed.Inactive {
+		inactive[plugin.ID] = true
+	}
+
+	actions := []*pluginAction{}
+	listed := map[string]bool{}
+	for _, plugin := range plugins {
+		id := *plugin.ID
+		listed[id] = true
+
+		if !active[id] && !inactive[id] {
+			if plugin.Source == nil || *plugin.Source == "" {
+				return nil, fmt.Errorf("plugin %q is not installed and has no source to install it from", id)
+			}
+			actions = append(actions, &pluginAction{Action: pluginActionInstall, ID: id, Source: *plugin.Source})
+			if plugin.IsEnabled() {
+				actions = append(actions, &pluginAction{Action: pluginActionEnable, ID: id})
+			}
+			continue
+		}
+
+		if plugin.IsEnabled() && !active[id] {
+			actions = append(actions, &pluginAction{Action: pluginActionEnable, ID: id})
+		} else if !plugin.IsEnabled() && active[id] {
+			actions = append(actions, &pluginAction{Action: pluginActionDisable, ID: id})
+		}
+	}
+
+	unlisted := []string{}
+	for id := range active {
+		if !listed[id] {
+			unlisted = append(unlisted, id)
+		}
+	}
+	sort.Strings(unlisted)
+
+	for _, id := range unlisted {
+		actions = append(actions, &pluginAction{Action: pluginActionDisable, ID: id})
+	}
+
+	return actions, nil
+}
+
+func applyPluginAction(action *pluginAction) error {
+	switch action.Action {
+	case pluginActionInstall:
+		return installPlugin(action.Source)
+	case pluginActionEnable:
+		_, err := runMmctl("plugin", "enable", action.ID)
+		return err
+	case pluginActionDisable:
+		_, err := runMmctl("plugin", "disable", action.ID)
+		return err
+	}
+	return fmt.Errorf("unknown plugin action %q", action.Action)
+}
+
+// convergePlugins applies the plugins section of the configuration to
+// the running server. If the section is not set, plugins are not
+// managed by reconfigure
+func convergePlugins(config *model.Config) error {
+	if len(config.Plugins) == 0 {
+		return nil
+	}
+
+	if !*config.EnableLocalMode {
+		return fmt.Errorf("enable_local_mode needs to be true to manage plugins")
+	}
+
+	// the server has just been restarted, so we wait for its local
+	// mode socket to be available
+	out, err := waitForMmctl(2*time.Minute, "plugin", "list", "--format", "json")
+	if err != nil {
+		return fmt.Errorf("error listing plugins: %w", err)
+	}
+
+	installed, err := parsePluginList(out)
+	if err != nil {
+		return err
+	}
+
+	actions, err := planPluginActions(config.Plugins, installed)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		fmt.Printf("Plugins: %s\n", action)
+		if err := applyPluginAction(action); err != nil {
+			return fmt.Errorf("error trying to %s: %w", action, err)
+		}
+	}
+
+	return nil
+}
+
+// planPlugins prints the plugin actions that reconfigure would apply
+func planPlugins(config *model.Config) {
+	if len(config.Plugins) == 0 {
+		return
+	}
+
+	installed, err := listPlugins()
+	if err != nil {
+		fmt.Printf("WARNING: cannot list the installed plugins, plugin changes are not shown: %s\n", err)
+		return
+	}
+
+	actions, err := planPluginActions(config.Plugins, installed)
+	if err != nil {
+		errAndExit(fmt.Errorf("error planning plugin changes: %w", err))
+	}
+
+	if len(actions) == 0 {
+		fmt.Println("Plugins: no changes")
+		return
+	}
+
+	for _, action := range actions {
+		fmt.Printf("Plugins: would %s\n", action)
+	}
+}
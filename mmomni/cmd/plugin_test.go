@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func TestParsePluginList(t *testing.T) {
+	t.Run("Should parse an object", func(t *testing.T) {
+		list, err := parsePluginList([]byte(`{"active": [{"id": "playbooks", "name": "Playbooks", "version": "1.0.0"}], "inactive": []}`))
+		require.NoError(t, err)
+		require.Len(t, list.Active, 1)
+		require.Equal(t, "playbooks", list.Active[0].ID)
+		require.Empty(t, list.Inactive)
+	})
+
+	t.Run("Should parse an array with a single object", func(t *testing.T) {
+		list, err := parsePluginList([]byte(`[{"active": [], "inactive": [{"id": "jitsi"}]}]`))
+		require.NoError(t, err)
+		require.Empty(t, list.Active)
+		require.Equal(t, "jitsi", list.Inactive[0].ID)
+	})
+
+	t.Run("Should fail with invalid output", func(t *testing.T) {
+		_, err := parsePluginList([]byte(`There are no plugins installed`))
+		require.Error(t, err)
+	})
+}
+
+func TestPlanPluginActions(t *testing.T) {
+	installed := &pluginList{
+		Active:   []*pluginInfo{{ID: "playbooks"}, {ID: "jitsi"}, {ID: "boards"}},
+		Inactive: []*pluginInfo{{ID: "matterpoll"}, {ID: "zoom"}},
+	}
+
+	t.Run("Should install, enable and disable plugins", func(t *testing.T) {
+		plugins := []*model.PluginConfig{
+			{ID: model.NewString("playbooks")},
+			{ID: model.NewString("matterpoll")},
+			{ID: model.NewString("boards"), Enabled: model.NewBool(false)},
+			{ID: model.NewString("github"), Source: model.NewString("https://example.com/github.tar.gz")},
+			{ID: model.NewString("todo"), Source: model.NewString("/tmp/todo.tar.gz"), Enabled: model.NewBool(false)},
+		}
+
+		actions, err := planPluginActions(plugins, installed)
+		require.NoError(t, err)
+		require.Equal(t, []*pluginAction{
+			{Action: pluginActionEnable, ID: "matterpoll"},
+			{Action: pluginActionDisable, ID: "boards"},
+			{Action: pluginActionInstall, ID: "github", Source: "https://example.com/github.tar.gz"},
+			{Action: pluginActionEnable, ID: "github"},
+			{Action: pluginActionInstall, ID: "todo", Source: "/tmp/todo.tar.gz"},
+			{Action: pluginActionDisable, ID: "jitsi"},
+		}, actions)
+	})
+
+	t.Run("Should do nothing if plugins are converged", func(t *testing.T) {
+		plugins := []*model.PluginConfig{
+			{ID: model.NewString("playbooks")},
+			{ID: model.NewString("jitsi")},
+			{ID: model.NewString("boards")},
+			{ID: model.NewString("zoom"), Enabled: model.NewBool(false)},
+		}
+
+		actions, err := planPluginActions(plugins, installed)
+		require.NoError(t, err)
+		require.Empty(t, actions)
+	})
+
+	t.Run("Should fail if a missing plugin has no source", func(t *testing.T) {
+		plugins := []*model.PluginConfig{{ID: model.NewString("github")}}
+
+		_, err := planPluginActions(plugins, installed)
+		require.Error(t, err)
+	})
+}
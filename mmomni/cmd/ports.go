@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// omnibusProcesses are the processes that are expected to listen on
+// the ports of the configuration
+var omnibusProcesses = []string{"nginx", "mattermost"}
+
+var ssProcessRegexp = regexp.MustCompile(`\("([^"]+)",pid=`)
+
+// parseSSOwners returns the names of the processes of the output of
+// ss -ltnp, sorted and without duplicates
+func parseSSOwners(out string) []string {
+	seen := map[string]bool{}
+	owners := []string{}
+	for _, match := range ssProcessRegexp.FindAllStringSubmatch(out, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			owners = append(owners, match[1])
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// portOwners returns the names of the processes listening on a TCP
+// port
+func portOwners(port int) ([]string, error) {
+	out, err := exec.Command("ss", "-H", "-ltnp", "sport = :"+strconv.Itoa(port)).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error checking port %d: %s: %w", port, strings.TrimSpace(string(out)), err)
+	}
+	return parseSSOwners(string(out)), nil
+}
+
+// checkPortConflicts returns an error if a port of the configuration
+// is used by a process that is not part of Omnibus
+func checkPortConflicts(config *model.Config, owners func(port int) ([]string, error)) error {
+	for _, port := range config.ListenPorts() {
+		processes, err := owners(port)
+		if err != nil {
+			return err
+		}
+
+		for _, process := range processes {
+			known := false
+			for _, omnibusProcess := range omnibusProcesses {
+				known = known || process == omnibusProcess
+			}
+			if !known {
+				return fmt.Errorf("port %d is already in use by %q", port, process)
+			}
+		}
+	}
+	return nil
+}
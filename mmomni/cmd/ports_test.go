@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func TestParseSSOwners(t *testing.T) {
+	out := `LISTEN 0      511          0.0.0.0:80        0.0.0.0:*    users:(("nginx",pid=1235,fd=6),("nginx",pid=1234,fd=6))
+LISTEN 0      511             [::]:80           [::]:*    users:(("nginx",pid=1235,fd=7),("apache2",pid=999,fd=4))
+`
+	require.Equal(t, []string{"apache2", "nginx"}, parseSSOwners(out))
+	require.Empty(t, parseSSOwners(""))
+}
+
+func TestCheckPortConflicts(t *testing.T) {
+	config := &model.Config{}
+	config.SetDefaults()
+
+	owners := map[int][]string{80: {"nginx"}, 8065: {"mattermost"}}
+	lookup := func(port int) ([]string, error) { return owners[port], nil }
+	require.NoError(t, checkPortConflicts(config, lookup))
+
+	owners[80] = []string{"apache2", "nginx"}
+	require.EqualError(t, checkPortConflicts(config, lookup), `port 80 is already in use by "apache2"`)
+
+	failing := func(port int) ([]string, error) { return nil, fmt.Errorf("ss not found") }
+	require.Error(t, checkPortConflicts(config, failing))
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+const (
+	MemInfoPath         = "/proc/meminfo"
+	PostgresConfigDir   = "/etc/postgresql"
+	PostgresDefaultPort = 5432
+)
+
+// postgresCluster is a PostgreSQL cluster as listed by pg_lsclusters
+type postgresCluster struct {
+	Version string
+	Name    string
+	Port    int
+	Status  string
+}
+
+// ConfigDir returns the configuration directory of the cluster
+func (c *postgresCluster) ConfigDir() string {
+	return filepath.Join(PostgresConfigDir, c.Version, c.Name)
+}
+
+// DropInPath returns the path of the drop-in written by mmomni
+func (c *postgresCluster) DropInPath() string {
+	return filepath.Join(c.ConfigDir(), "conf.d", model.POSTGRES_DROPIN_FILENAME)
+}
+
+// Service returns the systemd service of the cluster
+func (c *postgresCluster) Service() string {
+	return "postgresql@" + c.Version + "-" + c.Name
+}
+
+// parseMemInfo returns the total memory in MB of /proc/meminfo
+func parseMemInfo(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+
+		kb, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal %q: %w", fields[1], err)
+		}
+		return kb / 1024, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found")
+}
+
+// readHostResources returns the memory and CPUs of the host
+func readHostResources() (*model.HostResources, error) {
+	f, err := os.Open(MemInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	memoryMB, err := parseMemInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q: %w", MemInfoPath, err)
+	}
+
+	return &model.HostResources{MemoryMB: memoryMB, CPUs: runtime.NumCPU()}, nil
+}
+
+// parseClusters parses the output of pg_lsclusters --no-header
+func parseClusters(out string) ([]*postgresCluster, error) {
+	clusters := []*postgresCluster{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid cluster line %q", line)
+		}
+
+		port, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port for cluster %s/%s: %w", fields[0], fields[1], err)
+		}
+		clusters = append(clusters, &postgresCluster{Version: fields[0], Name: fields[1], Port: port, Status: fields[3]})
+	}
+	return clusters, nil
+}
+
+// selectCluster returns the cluster that Mattermost uses, which is the
+// one on the default port, or the only one of the host
+func selectCluster(clusters []*postgresCluster) (*postgresCluster, error) {
+	for _, cluster := range clusters {
+		if cluster.Port == PostgresDefaultPort {
+			return cluster, nil
+		}
+	}
+
+	if len(clusters) == 1 {
+		return clusters[0], nil
+	}
+	return nil, fmt.Errorf("cannot find the PostgreSQL cluster on port %d among %d clusters", PostgresDefaultPort, len(clusters))
+}
+
+// detectCluster returns the local PostgreSQL cluster of Mattermost
+func detectCluster() (*postgresCluster, error) {
+	clusters, err := listClusters()
+	if err != nil {
+		return nil, err
+	}
+	return selectCluster(clusters)
+}
+
+// postgresSettings computes the settings of the postgres section, and
+// returns nil if it's not set
+func postgresSettings(config *model.Config) (*model.PostgresSettings, error) {
+	if config.Postgres == nil {
+		return nil, nil
+	}
+
+	resources, err := readHostResources()
+	if err != nil {
+		return nil, fmt.Errorf("error reading host resources: %w", err)
+	}
+
+	settings, err := config.Postgres.Settings(resources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres configuration: %w", err)
+	}
+	return settings, nil
+}
+
+// postgresDropIn returns the current and the generated contents of
+// the drop-in. The generated content is empty if the postgres section
+// is not set, so the drop-in is removed
+func postgresDropIn(config *model.Config, cluster *postgresCluster) (string, string, error) {
+	current, err := ioutil.ReadFile(cluster.DropInPath())
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	settings, err := postgresSettings(config)
+	if err != nil {
+		return "", "", err
+	}
+
+	next := ""
+	if settings != nil {
+		next = settings.DropIn()
+	}
+	return string(current), next, nil
+}
+
+// configurePostgres writes the drop-in of the postgres section, and
+// reloads or restarts PostgreSQL only if the settings changed. Without
+// the postgres section, the drop-in of a previous configuration is
+// removed if the cluster can be found
+func configurePostgres(config *model.Config) error {
+	if config.ExternalDatabase() {
+		return nil
+	}
+
+	cluster, err := detectCluster()
+	if err != nil {
+		if config.Postgres == nil {
+			return nil
+		}
+		return err
+	}
+
+	current, next, err := postgresDropIn(config, cluster)
+	if err != nil {
+		return err
+	}
+
+	if current == next {
+		return nil
+	}
+
+	changes, restart := model.PostgresDropInChanges(current, next)
+	if next == "" {
+		if err := os.Remove(cluster.DropInPath()); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(cluster.DropInPath()), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(cluster.DropInPath(), []byte(next), 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	action := "reload"
+	if restart {
+		action = "restart"
+	}
+	fmt.Printf("PostgreSQL settings changed (%s), running %s\n", strings.Join(changes, ", "), action)
+
+	if out, err := exec.Command("systemctl", action, cluster.Service()).CombinedOutput(); err != nil {
+		return fmt.Errorf("error running %s of %s: %s: %w", action, cluster.Service(), strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// planPostgres prints the changes that configurePostgres would apply
+func planPostgres(config *model.Config) {
+	if config.Postgres == nil {
+		return
+	}
+
+	cluster, err := detectCluster()
+	if err != nil {
+		errAndExit(err)
+	}
+
+	current, next, err := postgresDropIn(config, cluster)
+	if err != nil {
+		errAndExit(err)
+	}
+
+	changes, restart := model.PostgresDropInChanges(current, next)
+	if len(changes) == 0 {
+		fmt.Println("PostgreSQL settings are up to date")
+		return
+	}
+
+	action := "reloaded"
+	if restart {
+		action = "restarted"
+	}
+	fmt.Printf("PostgreSQL settings would change (%s) and %s would be %s\n", strings.Join(changes, ", "), cluster.Service(), action)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMemInfo(t *testing.T) {
+	memInfo := "MemTotal:        8030412 kB\nMemFree:         1210044 kB\n"
+	memoryMB, err := parseMemInfo(strings.NewReader(memInfo))
+	require.NoError(t, err)
+	require.Equal(t, 7842, memoryMB)
+
+	_, err = parseMemInfo(strings.NewReader("MemFree: 1210044 kB\n"))
+	require.Error(t, err)
+}
+
+func TestPostgresClusters(t *testing.T) {
+	out := `13  main    5433 down   postgres /var/lib/postgresql/13/main  /var/log/postgresql/postgresql-13-main.log
+16  main    5432 online postgres /var/lib/postgresql/16/main  /var/log/postgresql/postgresql-16-main.log
+`
+	clusters, err := parseClusters(out)
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+
+	cluster, err := selectCluster(clusters)
+	require.NoError(t, err)
+	require.Equal(t, "16", cluster.Version)
+	require.Equal(t, "online", cluster.Status)
+	require.Equal(t, "postgresql@16-main", cluster.Service())
+	require.Equal(t, "/etc/postgresql/16/main/conf.d/mmomni.conf", cluster.DropInPath())
+
+	single, err := selectCluster(clusters[:1])
+	require.NoError(t, err)
+	require.Equal(t, "13", single.Version)
+
+	_, err = selectCluster([]*postgresCluster{})
+	require.Error(t, err)
+
+	_, err = parseClusters("13 main")
+	require.Error(t, err)
+}
@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -11,36 +17,311 @@ import (
 )
 
 func ReconfigureCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "reconfigure",
 		Short: "Reconfigures Mattermost Omnibus",
 		Long: `Generates and applies a Mattermost Omnibus configuration from the Omnibus configuration file
 
-This command should be run after modifying the /etc/mattermost/mmomni.yml configuration file to apply its changes and restart the platform`,
-		Example: `  $ mmomni reconfigure`,
-		Args:    cobra.NoArgs,
-		Run:     reconfigureCmdF,
+This command should be run after modifying the /etc/mattermost/mmomni.yml configuration file to apply its changes and restart the platform
+
+With the --plan flag, the configuration files are rendered with the current contents of mmomni.yml and compared with the deployed ones. The differences are shown as unified diffs, except for the Mattermost environment file as it contains secrets, together with the tasks that would change, the services that would be restarted or reloaded and the changes that cause it, and the plugins that would be installed, enabled or disabled. Nothing is modified on disk`,
+		Example: `  $ mmomni reconfigure
+
+  # preview the changes before applying them
+  $ mmomni reconfigure --plan`,
+		Args: cobra.NoArgs,
+		Run:  reconfigureCmdF,
+	}
+
+	cmd.Flags().Bool("plan", false, "Show the changes that reconfigure would apply without applying them")
+
+	return cmd
+}
+
+// playbookVars returns the variables that are computed from the
+// configuration and passed to the reconfigure playbook on top of the
+// ones it reads from the configuration file
+func playbookVars(config *model.Config) (map[string]interface{}, error) {
+	mattermostEnv, err := config.MattermostEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	smtpEnv := []*model.EnvVariable{}
+	if config.SMTP != nil {
+		smtpEnv = config.SMTP.Env()
+	}
+
+	fileStorageEnv := []*model.EnvVariable{}
+	if config.FileStorage != nil {
+		fileStorageEnv = config.FileStorage.Env()
+	}
+
+	databaseEnv := config.DatabaseEnv()
+	settings, err := postgresSettings(config)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		databaseEnv = append(databaseEnv, settings.Env()...)
+	}
+
+	aliases := []string{}
+	if config.Aliases != nil {
+		aliases = config.Aliases
+	}
+
+	bootstrapLockFile := ""
+	if bootstrapPending(config, model.BOOTSTRAP_DONE_PATH) {
+		bootstrapLockFile = model.BOOTSTRAP_LOCK_PATH
+	}
+
+	vars := map[string]interface{}{
+		"mattermost_env":      mattermostEnv,
+		"site_url":            config.SiteURL(),
+		"site_url_base":       config.SiteURLBase(),
+		"mattermost_listen":   *config.MattermostListen,
+		"subpath":             *config.Subpath,
+		"smtp_env":            smtpEnv,
+		"file_storage_env":    fileStorageEnv,
+		"database_env":        databaseEnv,
+		"database_external":   config.ExternalDatabase(),
+		"database_name":       *config.DatabaseSettings().Name,
+		"bootstrap_lock_file": bootstrapLockFile,
+		"aliases":             aliases,
+		"tls_directives":      config.NginxTLSDirectives(),
+	}
+	for name, value := range tlsVars(config) {
+		vars[name] = value
 	}
+	for name, value := range nginxVars(config) {
+		vars[name] = value
+	}
+
+	return vars, nil
 }
 
-func reconfigureCmdF(_ *cobra.Command, _ []string) {
+// validateMattermostSettings checks the mattermost_settings of the
+// configuration against the default configuration of the installed
+// Mattermost version
+func validateMattermostSettings(config *model.Config) error {
+	if len(config.MattermostSettings) == 0 {
+		return nil
+	}
+
+	defaults, err := model.ReadMattermostDefaults(model.MATTERMOST_DEFAULT_CONFIG)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "WARNING: cannot find %q, skipping mattermost_settings validation\n", model.MATTERMOST_DEFAULT_CONFIG)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error reading default Mattermost configuration: %w", err)
+	}
+
+	return model.ValidateMattermostSettings(config.MattermostSettings, defaults)
+}
+
+func runReconfigurePlaybook(config *model.Config, args ...string) error {
+	vars, err := playbookVars(config)
+	if err != nil {
+		return fmt.Errorf("error generating playbook variables: %w", err)
+	}
+
+	varsBytes, err := json.Marshal(vars)
+	if err != nil {
+		return fmt.Errorf("error generating playbook variables: %w", err)
+	}
+
+	varsFile, err := ioutil.TempFile(os.TempDir(), "mmomni_vars_*.json")
+	if err != nil {
+		return fmt.Errorf("error creating playbook variables file: %w", err)
+	}
+	defer os.Remove(varsFile.Name())
+
+	if _, err := varsFile.Write(varsBytes); err != nil {
+		varsFile.Close()
+		return fmt.Errorf("error writing playbook variables file: %w", err)
+	}
+	varsFile.Close()
+
+	ansibleArgs := append([]string{"/opt/mattermost/mmomni/ansible/playbooks/reconfigure.yml", "--extra-vars", "@" + varsFile.Name()}, args...)
+	ansibleCmd := exec.Command("ansible-playbook", ansibleArgs...)
+	ansibleCmd.Stdout = os.Stdout
+	ansibleCmd.Stderr = os.Stderr
+	ansibleCmd.Env = append(os.Environ(), "ANSIBLE_LIBRARY=/opt/mattermost/mmomni/ansible/modules")
+	return ansibleCmd.Run()
+}
+
+func reconfigureCmdF(cmd *cobra.Command, _ []string) {
+	plan, _ := cmd.Flags().GetBool("plan")
+
 	// we read the config from disk to validate it
 	config, err := model.ReadConfig(model.CONFIGPATH)
 	if err != nil {
 		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
 	}
+	printConfigChanges(config)
+
+	if err := validateConfig(config); err != nil {
+		errAndExit(fmt.Errorf("error validating configuration at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if plan {
+		reconfigurePlan(config)
+		planPostgres(config)
+		planPlugins(config)
+		return
+	}
+
+	if err := applyConfig(config); err != nil {
+		errAndExit(err)
+	}
+}
 
-	// and we save it before running reconfigure in case some defaults
-	// using during validation needed to be written
+// validateConfig runs the checks of the configuration that need to
+// inspect the host, such as DNS records, certificates or ports
+func validateConfig(config *model.Config) error {
+	if err := validateMattermostSettings(config); err != nil {
+		return err
+	}
+
+	if err := validateAliases(config, net.LookupHost); err != nil {
+		return err
+	}
+
+	if err := validateTLS(config); err != nil {
+		return err
+	}
+
+	if err := validateAccessControl(config); err != nil {
+		return err
+	}
+
+	if err := validateDatabase(config); err != nil {
+		return err
+	}
+
+	return checkPortConflicts(config, portOwners)
+}
+
+// applyConfig saves a validated configuration and converges the
+// platform to it
+func applyConfig(config *model.Config) error {
+	// we save it before running reconfigure in case some defaults
+	// using during validation or some migrations needed to be written
 	if err := config.Save(); err != nil {
-		errAndExit(fmt.Errorf("error updating configuration at %q: %w", model.CONFIGPATH, err))
+		return fmt.Errorf("error updating configuration at %q: %w", model.CONFIGPATH, err)
 	}
 
-	ansibleCmd := exec.Command("ansible-playbook", "/opt/mattermost/mmomni/ansible/playbooks/reconfigure.yml")
-	ansibleCmd.Stdout = os.Stdout
-	ansibleCmd.Stderr = os.Stderr
-	ansibleCmd.Env = append(os.Environ(), "ANSIBLE_LIBRARY=/opt/mattermost/mmomni/ansible/modules")
-	if err := ansibleCmd.Run(); err != nil {
-		errAndExit(fmt.Errorf("error running reconfigure: %w", err))
+	if err := prepareTLS(config); err != nil {
+		return err
+	}
+
+	// the lock is created before NGINX is configured, so the server is
+	// never reachable before the bootstrap admin exists
+	if bootstrapPending(config, model.BOOTSTRAP_DONE_PATH) {
+		if err := lockBootstrap(model.BOOTSTRAP_LOCK_PATH); err != nil {
+			return fmt.Errorf("error creating bootstrap lock file: %w", err)
+		}
+	}
+
+	if err := configurePostgres(config); err != nil {
+		return fmt.Errorf("error configuring PostgreSQL: %w", err)
+	}
+
+	if err := runReconfigurePlaybook(config); err != nil {
+		return fmt.Errorf("error running reconfigure: %w", err)
+	}
+
+	if err := runBootstrap(config, model.BOOTSTRAP_DONE_PATH, model.BOOTSTRAP_LOCK_PATH); err != nil {
+		return fmt.Errorf("error applying bootstrap, the server will reject requests until it succeeds: %w", err)
+	}
+
+	if err := convergePlugins(config); err != nil {
+		return fmt.Errorf("error configuring plugins: %w", err)
+	}
+
+	return nil
+}
+
+// reconfigurePlan runs the reconfigure playbook in check mode. As the
+// configuration files can't be updated, the validated config and its
+// secrets are written to temporary files that the playbook reads instead
+func reconfigurePlan(config *model.Config) {
+	dir, err := ioutil.TempDir(os.TempDir(), "mmomni_")
+	if err != nil {
+		errAndExit(fmt.Errorf("error creating temp directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+
+	planConfig, err := config.Clone()
+	if err != nil {
+		errAndExit(fmt.Errorf("error preparing configuration: %w", err))
+	}
+
+	planConfig.Path = filepath.Join(dir, filepath.Base(model.CONFIGPATH))
+	planConfig.SecretsFile = model.NewString(filepath.Join(dir, model.SECRETS_FILENAME))
+	if err := planConfig.Save(); err != nil {
+		errAndExit(fmt.Errorf("error writing configuration to %q: %w", planConfig.Path, err))
+	}
+
+	outputPath := filepath.Join(dir, "restarts.json")
+	extraVars := fmt.Sprintf("mmomni_config=%s mmomni_secrets=%s mmomni_plan_output=%s", planConfig.Path, *planConfig.SecretsFile, outputPath)
+	if err := runReconfigurePlaybook(planConfig, "--check", "--diff", "--extra-vars", extraVars); err != nil {
+		// cleanup needs to happen before exiting
+		os.RemoveAll(dir)
+		errAndExit(fmt.Errorf("error running reconfigure plan: %w", err))
 	}
+
+	restarts, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		errAndExit(fmt.Errorf("error reading the services that would restart: %w", err))
+	}
+
+	summary, err := restartSummary(restarts)
+	if err != nil {
+		os.RemoveAll(dir)
+		errAndExit(fmt.Errorf("error reading the services that would restart: %w", err))
+	}
+
+	fmt.Println()
+	for _, line := range summary {
+		fmt.Println(line)
+	}
+}
+
+// restartSummary describes the services that reconfigure restarts and
+// the changes that cause it, from the changes stored by the playbook
+// for each service
+func restartSummary(data []byte) ([]string, error) {
+	var services map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := []string{}
+	for _, name := range names {
+		changes := []string{}
+		for change, changed := range services[name] {
+			// ansible can render the booleans as strings
+			if changed == true || fmt.Sprint(changed) == "True" || fmt.Sprint(changed) == "true" {
+				changes = append(changes, change)
+			}
+		}
+		sort.Strings(changes)
+
+		if len(changes) == 0 {
+			summary = append(summary, fmt.Sprintf("%s would be restarted, without configuration changes", name))
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s would be restarted to apply the changes to its %s", name, strings.Join(changes, ", ")))
+	}
+
+	return summary, nil
 }
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartSummary(t *testing.T) {
+	summary, err := restartSummary([]byte(`{
+		"nginx": {"certificate": false, "configuration": true},
+		"mattermost": {"environment": "True", "subpath": "False", "systemd unit": true}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"mattermost would be restarted to apply the changes to its environment, systemd unit",
+		"nginx would be restarted to apply the changes to its configuration",
+	}, summary)
+
+	summary, err = restartSummary([]byte(`{"nginx": {"configuration": false}}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"nginx would be restarted, without configuration changes"}, summary)
+
+	_, err = restartSummary([]byte(`not json`))
+	require.Error(t, err)
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
@@ -106,12 +105,24 @@ func restoreCmdF(cmd *cobra.Command, args []string) {
 	if err != nil {
 		errAndExit(fmt.Errorf("error reading extracted Omnibus configuration at %q: %w", tmpConfigPath, err))
 	}
+	// reading the configuration migrates it in case we're restoring a
+	// backup from an older Omnibus version
+	printConfigChanges(config)
+
+	// the secrets are read from the backup instead of from the secrets
+	// file referenced by the restored configuration
+	tmpSecretsPath := filepath.Join(dir, model.SECRETS_FILENAME)
+	if err := config.LoadSecrets(tmpSecretsPath); err != nil {
+		errAndExit(fmt.Errorf("error reading extracted Omnibus secrets at %q: %w", tmpSecretsPath, err))
+	}
 
+	// the database settings and secrets file location of the current
+	// installation are kept, as the database is reused
 	config.DBUser = oldConfig.DBUser
 	config.DBPassword = oldConfig.DBPassword
-	// update the configuration in case we're restoring a backup from
-	// an older Omnibus version
-	config.SetDefaults()
+	config.Database = oldConfig.Database
+	config.Postgres = oldConfig.Postgres
+	config.SecretsFile = oldConfig.SecretsFile
 
 	config.Path = model.CONFIGPATH
 	if err := config.Save(); err != nil {
@@ -122,8 +133,7 @@ func restoreCmdF(cmd *cobra.Command, args []string) {
 
 	// Import pgdump
 	dumpFilePath := filepath.Join(dir, "database.dump")
-	pgRestoreCmd := exec.Command("pg_restore", "-Fc", "-c", "-d", "mattermost", dumpFilePath, "-w", "-U", *config.DBUser, "-h", "localhost")
-	pgRestoreCmd.Env = append(pgRestoreCmd.Env, "PGPASSWORD="+*config.DBPassword)
+	pgRestoreCmd := postgresClientCommand(config, "pg_restore", "-Fc", "-c", "-d", *config.DatabaseSettings().Name, dumpFilePath, "-w")
 	pgRestoreCmd.Stdout = os.Stdout
 	pgRestoreCmd.Stderr = os.Stderr
 	if err := pgRestoreCmd.Run(); err != nil {
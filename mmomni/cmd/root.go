@@ -22,12 +22,21 @@ func RootCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(
+		AdminCmd(),
 		BackupCmd(),
+		CertCmd(),
+		ConfigCmd(),
+		DbCmd(),
 		DocsCmd(),
 		InitCmd(),
+		MmCmd(),
+		PluginCmd(),
 		ReconfigureCmd(),
 		RestoreCmd(),
+		SecretsCmd(),
+		SMTPCmd(),
 		StatusCmd(),
+		StorageCmd(),
 		TailCmd(),
 	)
 
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func SecretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manages the Omnibus secrets",
+		Long:  "Manages the sensitive values of the Mattermost Omnibus configuration, which are stored in the secrets file referenced by the secrets_file property of /etc/mattermost/mmomni.yml",
+	}
+
+	cmd.AddCommand(
+		SecretsRotateCmd(),
+	)
+
+	return cmd
+}
+
+func SecretsRotateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate <secret>",
+		Short: "Rotates a secret",
+		Long: `Generates a new value for a secret, stores it in the secrets file and reconfigures the platform so all the components use the new value
+
+The available secrets are:
+  db-password   the password of the Mattermost database user`,
+		Example:   `  $ mmomni secrets rotate db-password`,
+		ValidArgs: []string{"db-password"},
+		Args:      cobra.ExactValidArgs(1),
+		Run:       secretsRotateCmdF,
+	}
+}
+
+func secretsRotateCmdF(_ *cobra.Command, args []string) {
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+	printConfigChanges(config)
+
+	if err := validateConfig(config); err != nil {
+		errAndExit(fmt.Errorf("error validating configuration at %q: %w", model.CONFIGPATH, err))
+	}
+
+	switch args[0] {
+	case "db-password":
+		password, err := CreatePassword()
+		if err != nil {
+			errAndExit(fmt.Errorf("error generating password: %w", err))
+		}
+		if config.ExternalDatabase() {
+			rotateExternalDatabasePassword(config, password)
+		}
+		config.DBPassword = model.NewString(password)
+	}
+
+	fmt.Printf("Rotating secret %q and reconfiguring the platform\n", args[0])
+
+	// reconfigure updates the database user, regenerates the
+	// Mattermost environment and restarts the service
+	if err := applyConfig(config); err != nil {
+		errAndExit(fmt.Errorf("error rotating secret %q: %w", args[0], err))
+	}
+}
+
+// rotateExternalDatabasePassword changes the password of the user of
+// an external database, as the playbook only manages the local server.
+// The new password is saved before it's changed, so it's never lost,
+// and the previous one is restored if the change fails
+func rotateExternalDatabasePassword(config *model.Config, password string) {
+	previous := config.DBPassword
+	config.DBPassword = model.NewString(password)
+	if err := config.Save(); err != nil {
+		errAndExit(fmt.Errorf("error saving the new database password: %w", err))
+	}
+
+	if err := alterDatabasePassword(config, password); err != nil {
+		config.DBPassword = previous
+		if saveErr := config.Save(); saveErr != nil {
+			errAndExit(fmt.Errorf("error changing the password of the external database: %w. The previous password could not be restored in %q, the database still uses %q: %s", err, *config.SecretsFile, *previous, saveErr))
+		}
+		errAndExit(fmt.Errorf("error changing the password of the external database, the previous password was kept: %w", err))
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func SMTPCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "smtp",
+		Short: "Manages the SMTP configuration",
+		Long:  "Manages the SMTP server used by Mattermost to send email notifications, configured in the smtp section of /etc/mattermost/mmomni.yml",
+	}
+
+	cmd.AddCommand(
+		SMTPTestCmd(),
+	)
+
+	return cmd
+}
+
+func SMTPTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "test",
+		Short:   "Sends a test email",
+		Long:    "Sends a test email using the SMTP configuration of the mmomni.yml file, to check that the SMTP server settings are correct",
+		Example: `  $ mmomni smtp test --to admin@example.com`,
+		Args:    cobra.NoArgs,
+		Run:     smtpTestCmdF,
+	}
+
+	cmd.Flags().String("to", "", "The address to send the test email to")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func smtpTestCmdF(cmd *cobra.Command, _ []string) {
+	to, _ := cmd.Flags().GetString("to")
+
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if !config.SMTP.IsEnabled() {
+		errAndExit(fmt.Errorf("smtp is not configured in %q", model.CONFIGPATH))
+	}
+
+	if err := sendTestEmail(config.SMTP, to, time.Now()); err != nil {
+		errAndExit(fmt.Errorf("error sending test email: %w", err))
+	}
+
+	fmt.Printf("Test email sent to %q\n", to)
+}
+
+func sendTestEmail(smtpConfig *model.SMTPConfig, to string, t time.Time) error {
+	from, err := mail.ParseAddress(*smtpConfig.FromAddress)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", *smtpConfig.FromAddress, err)
+	}
+
+	toAddress, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid destination address %q: %w", to, err)
+	}
+
+	host := *smtpConfig.Host
+	addr := net.JoinHostPort(host, strconv.Itoa(*smtpConfig.Port))
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if *smtpConfig.Security == model.SMTP_SECURITY_TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if *smtpConfig.Security == model.SMTP_SECURITY_STARTTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("cannot start TLS: %w", err)
+		}
+	}
+
+	if *smtpConfig.Username != "" {
+		password := ""
+		if smtpConfig.Password != nil {
+			password = *smtpConfig.Password
+		}
+
+		if err := client.Auth(smtp.PlainAuth("", *smtpConfig.Username, password, host)); err != nil {
+			return fmt.Errorf("cannot authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("sender %q rejected: %w", from.Address, err)
+	}
+
+	if err := client.Rcpt(toAddress.Address); err != nil {
+		return fmt.Errorf("recipient %q rejected: %w", toAddress.Address, err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	message := strings.Join([]string{
+		"From: " + from.String(),
+		"To: " + toAddress.String(),
+		"Subject: Mattermost Omnibus test email",
+		"Date: " + t.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		"This is a test email sent by mmomni to check the SMTP configuration of Mattermost Omnibus.",
+		"",
+	}, "\r\n")
+
+	if _, err := w.Write([]byte(message)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+// smtpSink accepts a single SMTP session and sends the received
+// envelope and message through the returned channel
+func smtpSink(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := make(chan []string, 1)
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		lines := []string{}
+		_ = tp.PrintfLine("220 sink ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 sink")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				lines = append(lines, data...)
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				received <- lines
+				return
+			default:
+				_ = tp.PrintfLine("502 unknown command")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSendTestEmail(t *testing.T) {
+	t.Run("Should send the test email to a local SMTP server", func(t *testing.T) {
+		addr, received := smtpSink(t)
+		host, portStr, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+		port, err := net.LookupPort("tcp", portStr)
+		require.NoError(t, err)
+
+		smtpConfig := &model.SMTPConfig{
+			Host:        model.NewString(host),
+			Port:        model.NewInt(port),
+			Security:    model.NewString(model.SMTP_SECURITY_NONE),
+			FromAddress: model.NewString("Mattermost <noreply@example.com>"),
+		}
+		smtpConfig.SetDefaults()
+		require.NoError(t, smtpConfig.IsValid())
+
+		require.NoError(t, sendTestEmail(smtpConfig, "admin@example.com", time.Now()))
+
+		select {
+		case lines := <-received:
+			require.Equal(t, "MAIL FROM:<noreply@example.com>", strings.SplitN(lines[0], " BODY", 2)[0])
+			require.Equal(t, "RCPT TO:<admin@example.com>", lines[1])
+			require.Contains(t, lines, "From: \"Mattermost\" <noreply@example.com>")
+			require.Contains(t, lines, "Subject: Mattermost Omnibus test email")
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the SMTP sink didn't receive the message")
+		}
+	})
+
+	t.Run("Should fail if the server is not reachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		smtpConfig := &model.SMTPConfig{
+			Host:        model.NewString("127.0.0.1"),
+			Port:        model.NewInt(port),
+			Security:    model.NewString(model.SMTP_SECURITY_NONE),
+			FromAddress: model.NewString("noreply@example.com"),
+		}
+		smtpConfig.SetDefaults()
+
+		require.Error(t, sendTestEmail(smtpConfig, "admin@example.com", time.Now()))
+	})
+}
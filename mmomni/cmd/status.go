@@ -1,12 +1,36 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+const (
+	NginxAccessLog = "/var/log/nginx/access.log"
+
+	// rateLimitWindow is the period of the access log inspected to
+	// report the rate limited requests
+	rateLimitWindow = 24 * time.Hour
+)
+
+var (
+	// accessLogRegexp matches the client, time, path and status of the
+	// combined log format lines
+	accessLogRegexp     = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "\S+ (\S+)[^"]*" (\d{3}) `)
+	websocketPathRegexp = regexp.MustCompile(`^/api/v[0-9]+/(users/)?websocket$`)
+	loginPathRegexp     = regexp.MustCompile(`^/api/v[0-9]+/users/login`)
 )
 
 func StatusCmd() *cobra.Command {
@@ -42,8 +66,94 @@ func getServiceStatus(name string) (string, int, error) {
 	return state, pid, nil
 }
 
+// rateLimitedEndpoint classifies a request path by the rate limit that
+// applies to it
+func rateLimitedEndpoint(path string) string {
+	switch {
+	case websocketPathRegexp.MatchString(path), strings.HasPrefix(path, "/plugins/focalboard/ws/"):
+		return "websocket"
+	case loginPathRegexp.MatchString(path):
+		return "login"
+	case strings.HasPrefix(path, "/api/"):
+		return "api"
+	}
+	return "other"
+}
+
+// countRateLimited counts the requests of an access log answered with
+// a 429 status since the given time, by endpoint
+func countRateLimited(r io.Reader, config *model.Config, since time.Time) (map[string]int, error) {
+	counts := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		match := accessLogRegexp.FindStringSubmatch(scanner.Text())
+		if match == nil || match[4] != "429" {
+			continue
+		}
+
+		at, err := time.Parse("02/Jan/2006:15:04:05 -0700", match[2])
+		if err != nil || at.Before(since) {
+			continue
+		}
+
+		path := config.TrimSubpath(strings.SplitN(match[3], "?", 2)[0])
+		counts[rateLimitedEndpoint(path)]++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// rateLimitedSummary formats the 429 counts of countRateLimited
+func rateLimitedSummary(counts map[string]int) string {
+	total := 0
+	endpoints := []string{}
+	for endpoint, count := range counts {
+		total += count
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	details := []string{}
+	for _, endpoint := range endpoints {
+		details = append(details, fmt.Sprintf("%s: %d", endpoint, counts[endpoint]))
+	}
+
+	summary := fmt.Sprintf("Rate limited requests in the last %d hours: %d", int(rateLimitWindow.Hours()), total)
+	if len(details) != 0 {
+		summary += " (" + strings.Join(details, ", ") + ")"
+	}
+	return summary
+}
+
+// externalDatabaseStatus checks if the external database accepts
+// connections
+func externalDatabaseStatus(config *model.Config) string {
+	out, err := postgresClientCommand(config, "pg_isready", "-t", "5").CombinedOutput()
+	status := strings.TrimSpace(string(out))
+	if err != nil && status == "" {
+		status = err.Error()
+	}
+	return status
+}
+
 func statusCmdF(_ *cobra.Command, _ []string) {
-	services := []string{"nginx", "postgresql@13-main", "mattermost"}
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	services := []string{"nginx", "mattermost"}
+	if !config.ExternalDatabase() {
+		postgresService := "postgresql"
+		if cluster, err := detectCluster(); err == nil {
+			postgresService = cluster.Service()
+		}
+		services = []string{"nginx", postgresService, "mattermost"}
+	}
 
 	for _, service := range services {
 		state, pid, err := getServiceStatus(service)
@@ -53,4 +163,37 @@ func statusCmdF(_ *cobra.Command, _ []string) {
 
 		fmt.Printf("[%d] %s: %s", pid, service, state)
 	}
+
+	if config.ExternalDatabase() {
+		fmt.Printf("external database: %s\n", externalDatabaseStatus(config))
+	}
+
+	if state, err := readUpgradeState(DBUpgradeStatePath); err == nil && state != nil {
+		fmt.Printf("NOTICE: the database upgrade from PostgreSQL %s to %s is pending, run \"mmomni db upgrade --finalize\" or \"mmomni db upgrade --rollback\"\n", state.From, state.To)
+	}
+
+	if *config.HTTPS {
+		certPath, _ := config.TLSCertificate()
+		if warning := certExpiryWarning(certPath, time.Now()); warning != "" {
+			fmt.Printf("WARNING: %s\n", warning)
+		}
+	}
+
+	if config.Nginx != nil && config.Nginx.RateLimits != nil {
+		// the access log may be missing after a rotation, which
+		// shouldn't make the status command fail
+		accessLog, err := os.Open(NginxAccessLog)
+		if err != nil {
+			fmt.Printf("WARNING: cannot open the NGINX access log to count rate limited requests: %s\n", err)
+			return
+		}
+		defer accessLog.Close()
+
+		counts, err := countRateLimited(accessLog, config, time.Now().Add(-rateLimitWindow))
+		if err != nil {
+			fmt.Printf("WARNING: cannot read the NGINX access log to count rate limited requests: %s\n", err)
+			return
+		}
+		fmt.Println(rateLimitedSummary(counts))
+	}
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func TestCountRateLimited(t *testing.T) {
+	accessLog := strings.Join([]string{
+		`203.0.113.5 - - [19/Oct/2026:09:59:00 +0000] "POST /api/v4/users/login HTTP/2.0" 429 0 "-" "curl/8.0"`,
+		`203.0.113.5 - - [19/Oct/2026:10:01:00 +0000] "POST /api/v4/users/login HTTP/2.0" 429 0 "-" "curl/8.0"`,
+		`203.0.113.5 - - [19/Oct/2026:10:01:01 +0000] "POST /api/v4/users/login?redirect=1 HTTP/2.0" 429 0 "-" "curl/8.0"`,
+		`203.0.113.6 - - [19/Oct/2026:10:02:00 +0000] "GET /api/v4/users/websocket HTTP/1.1" 429 0 "-" "Mozilla/5.0"`,
+		`203.0.113.7 - - [19/Oct/2026:10:03:00 +0000] "GET /api/v4/posts/abc HTTP/2.0" 429 0 "-" "Mozilla/5.0"`,
+		`203.0.113.7 - - [19/Oct/2026:10:04:00 +0000] "GET /api/v4/posts/abc HTTP/2.0" 200 512 "-" "Mozilla/5.0"`,
+		`not an access log line`,
+	}, "\n")
+
+	config := &model.Config{}
+	config.SetDefaults()
+
+	since := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	counts, err := countRateLimited(strings.NewReader(accessLog), config, since)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"login": 2, "websocket": 1, "api": 1}, counts)
+	require.Equal(t, "Rate limited requests in the last 24 hours: 4 (api: 1, login: 2, websocket: 1)", rateLimitedSummary(counts))
+	require.Equal(t, "Rate limited requests in the last 24 hours: 0", rateLimitedSummary(map[string]int{}))
+
+	t.Run("Requests under a subpath", func(t *testing.T) {
+		config.Subpath = model.NewString("/chat")
+		accessLog := `203.0.113.5 - - [19/Oct/2026:10:01:00 +0000] "POST /chat/api/v4/users/login HTTP/2.0" 429 0 "-" "curl/8.0"`
+
+		counts, err := countRateLimited(strings.NewReader(accessLog), config, since)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"login": 1}, counts)
+	})
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+	"github.com/mattermost/mattermost-omnibus/mmomni/storage"
+)
+
+func StorageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Manages the file storage",
+		Long:  "Manages the backend where Mattermost stores attachments and plugins, configured in the file_storage section of /etc/mattermost/mmomni.yml",
+	}
+
+	cmd.AddCommand(
+		StorageMigrateCmd(),
+	)
+
+	return cmd
+}
+
+func StorageMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrates the files to a different storage",
+		Long: `Copies the files of the data directory to the S3 bucket configured in the file_storage.s3 section of the configuration file
+
+Files that already exist in the bucket with the same size and checksum are skipped, so the command can be run again to resume an interrupted migration. Every uploaded file is verified against its MD5 checksum.
+
+To migrate with minimal downtime, run the command once with Mattermost running, then stop the Mattermost service, run the command again to copy the files created in the meantime, set file_storage.driver to "s3" and run "mmomni reconfigure"`,
+		Example: `  $ mmomni storage migrate --to s3`,
+		Args:    cobra.NoArgs,
+		Run:     storageMigrateCmdF,
+	}
+
+	cmd.Flags().String("to", "", `The destination storage. The only supported value is "s3"`)
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+type storageMigrationResult struct {
+	Uploaded int
+	Skipped  int
+	Failed   []string
+}
+
+func storageMigrateCmdF(cmd *cobra.Command, _ []string) {
+	to, _ := cmd.Flags().GetString("to")
+	if to != model.FILE_STORAGE_DRIVER_S3 {
+		errAndExit(fmt.Errorf("unsupported destination %q, only %q is supported", to, model.FILE_STORAGE_DRIVER_S3))
+	}
+
+	config, err := model.ReadConfig(model.CONFIGPATH)
+	if err != nil {
+		errAndExit(fmt.Errorf("error reading config at %q: %w", model.CONFIGPATH, err))
+	}
+
+	if config.FileStorage == nil || config.FileStorage.S3 == nil {
+		errAndExit(fmt.Errorf("the file_storage.s3 section needs to be configured in %q", model.CONFIGPATH))
+	}
+
+	s3Config := config.FileStorage.S3
+	if *s3Config.AccessKeyID == "" {
+		errAndExit(fmt.Errorf("access_key_id and secret_access_key are required to migrate the files"))
+	}
+
+	client := storage.NewS3Client(*s3Config.Endpoint, *s3Config.Region, *s3Config.Bucket, *s3Config.AccessKeyID, *s3Config.SecretAccessKey, *s3Config.SSL, *s3Config.SSE)
+
+	fmt.Printf("Migrating files from %q to bucket %q\n", *config.DataDirectory, *s3Config.Bucket)
+	result, err := migrateFilesToS3(*config.DataDirectory, *s3Config.PathPrefix, client)
+	if err != nil {
+		errAndExit(fmt.Errorf("error migrating files: %w", err))
+	}
+
+	fmt.Printf("\n%d files uploaded, %d files already up to date\n", result.Uploaded, result.Skipped)
+	if len(result.Failed) > 0 {
+		errAndExit(fmt.Errorf("%d files could not be migrated, run the command again to retry them", len(result.Failed)))
+	}
+
+	if !config.FileStorage.IsS3() {
+		fmt.Println("\nSet file_storage.driver to \"s3\" and run \"mmomni reconfigure\" to start using the bucket")
+	}
+}
+
+// fileChecksums returns the MD5 and SHA256 checksums of a file
+func fileChecksums(filePath string) ([]byte, []byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file); err != nil {
+		return nil, nil, err
+	}
+
+	return md5Hash.Sum(nil), sha256Hash.Sum(nil), nil
+}
+
+func migrateFileToS3(client *storage.S3Client, filePath, key string, size int64) (bool, error) {
+	md5sum, sha256sum, err := fileChecksums(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := client.HeadObject(key)
+	if err != nil {
+		return false, err
+	}
+
+	if info != nil && info.Size == size && info.ETag == hex.EncodeToString(md5sum) {
+		return false, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	if err := client.PutObject(key, file, size, md5sum, sha256sum); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// migrateFilesToS3 uploads the files of dataDir to the bucket, using
+// their path relative to dataDir under prefix as the object key. Files
+// that fail to upload are reported and skipped
+func migrateFilesToS3(dataDir, prefix string, client *storage.S3Client) (*storageMigrationResult, error) {
+	result := &storageMigrationResult{Failed: []string{}}
+
+	err := filepath.Walk(dataDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dataDir, filePath)
+		if err != nil {
+			return err
+		}
+		key := path.Join(prefix, filepath.ToSlash(rel))
+
+		uploaded, err := migrateFileToS3(client, filePath, key, info.Size())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: cannot migrate %q: %s\n", filePath, err)
+			result.Failed = append(result.Failed, filePath)
+			return nil
+		}
+
+		if uploaded {
+			fmt.Printf("Uploaded %s\n", key)
+			result.Uploaded++
+		} else {
+			result.Skipped++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 stores the objects uploaded with PUT in memory and serves
+// their metadata with HEAD
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[r.URL.Path] = data
+		f.puts++
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestMigrateFilesToS3(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "teams", "team1"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "teams", "team1", "image one.png"), []byte("image"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plugin.tar.gz"), []byte("plugin"), 0644))
+
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	client := storage.NewS3Client(serverURL.Host, "us-east-1", "bucket", "access", "secret", false, false)
+
+	t.Run("Should upload all files under the prefix", func(t *testing.T) {
+		result, err := migrateFilesToS3(dir, "mattermost", client)
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Uploaded)
+		require.Equal(t, 0, result.Skipped)
+		require.Empty(t, result.Failed)
+
+		require.Equal(t, []byte("image"), fake.objects["/bucket/mattermost/teams/team1/image one.png"])
+		require.Equal(t, []byte("plugin"), fake.objects["/bucket/mattermost/plugin.tar.gz"])
+	})
+
+	t.Run("Should skip files that are already up to date when resuming", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plugin.tar.gz"), []byte("plugin v2"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644))
+		putsBefore := fake.puts
+
+		result, err := migrateFilesToS3(dir, "mattermost", client)
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Uploaded)
+		require.Equal(t, 1, result.Skipped)
+		require.Equal(t, putsBefore+2, fake.puts)
+		require.Equal(t, []byte("plugin v2"), fake.objects["/bucket/mattermost/plugin.tar.gz"])
+	})
+
+	t.Run("Should report the files that fail to upload", func(t *testing.T) {
+		badClient := storage.NewS3Client(serverURL.Host, "us-east-1", "bucket", "access", "secret", false, false)
+		badClient.HTTPClient = &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader("boom")), Request: r}, nil
+		})}
+
+		result, err := migrateFilesToS3(dir, "", badClient)
+		require.NoError(t, err)
+		require.Len(t, result.Failed, 3)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
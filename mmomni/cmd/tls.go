@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/certs"
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+// selfSignedPaths returns the files of the selfsigned TLS mode
+func selfSignedPaths(config *model.Config) *certs.SelfSignedPaths {
+	caCert, caKey := model.SelfSignedCA()
+	cert, key := config.TLSCertificate()
+	return &certs.SelfSignedPaths{CACert: caCert, CAKey: caKey, Cert: cert, Key: key}
+}
+
+// validateTLS checks that the files referenced by the tls section
+// exist, and that the custom certificate can be served for the fqdn
+func validateTLS(config *model.Config) error {
+	if !*config.HTTPS {
+		return nil
+	}
+
+	if config.TLSMode() == model.TLS_MODE_LETSENCRYPT && config.TLS != nil && config.TLS.ACME != nil {
+		for _, path := range []string{*config.TLS.ACME.DNSCredentialsFile, *config.TLS.ACME.CABundle} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("invalid acme configuration: %w", err)
+			}
+		}
+	}
+
+	if config.TLSMode() != model.TLS_MODE_CUSTOM {
+		return nil
+	}
+
+	chainFile := ""
+	if config.TLS.ChainFile != nil {
+		chainFile = *config.TLS.ChainFile
+	}
+
+	if err := certs.ValidateCustom(*config.TLS.CertFile, *config.TLS.KeyFile, chainFile, config.Hostnames(), time.Now()); err != nil {
+		return fmt.Errorf("invalid custom certificate: %w", err)
+	}
+	return nil
+}
+
+// prepareTLS generates the certificate of the selfsigned mode if it
+// doesn't exist or needs to be renewed
+func prepareTLS(config *model.Config) error {
+	if !*config.HTTPS || config.TLSMode() != model.TLS_MODE_SELFSIGNED {
+		return nil
+	}
+
+	paths := selfSignedPaths(config)
+	generated, err := certs.EnsureSelfSigned(paths, config.Hostnames(), time.Now())
+	if err != nil {
+		return fmt.Errorf("error generating selfsigned certificate: %w", err)
+	}
+
+	if generated {
+		fmt.Printf("Selfsigned certificate generated for %s. Clients need to trust the local CA at %q\n", strings.Join(config.Hostnames(), ", "), paths.CACert)
+	}
+	return nil
+}
+
+// tlsVars returns the playbook variables with the certificate that
+// NGINX serves
+func tlsVars(config *model.Config) map[string]interface{} {
+	cert, key := config.TLSCertificate()
+	vars := map[string]interface{}{
+		"tls_mode":              config.TLSMode(),
+		"tls_certificate":       cert,
+		"tls_certificate_key":   key,
+		"tls_custom_cert_file":  "",
+		"tls_custom_chain_file": "",
+		"certbot_args":          []string{},
+		"certbot_env":           map[string]string{},
+		"certbot_no_log":        false,
+		"certbot_reissue":       false,
+	}
+
+	if config.TLSMode() == model.TLS_MODE_LETSENCRYPT {
+		certbotEnv := map[string]string{}
+		noLog := false
+		if config.TLS != nil && config.TLS.ACME != nil {
+			// certbot uses the requests library, which reads the CAs
+			// to trust from this variable
+			if *config.TLS.ACME.CABundle != "" {
+				certbotEnv["REQUESTS_CA_BUNDLE"] = *config.TLS.ACME.CABundle
+			}
+			noLog = *config.TLS.ACME.EABKeyID != ""
+		}
+
+		// an existing certificate is only renewed by certbot, so it
+		// needs to be requested again if the hostnames changed
+		reissue := false
+		if chain, err := certs.ReadCertificates(cert); err == nil {
+			reissue = certs.CoversHostnames(chain[0], config.Hostnames()) != nil
+		}
+
+		vars["certbot_args"] = config.CertbotArgs()
+		vars["certbot_reissue"] = reissue
+		vars["certbot_env"] = certbotEnv
+		vars["certbot_no_log"] = noLog
+	}
+
+	if config.TLSMode() == model.TLS_MODE_CUSTOM {
+		vars["tls_custom_cert_file"] = *config.TLS.CertFile
+		if config.TLS.ChainFile != nil {
+			vars["tls_custom_chain_file"] = *config.TLS.ChainFile
+		}
+	}
+
+	return vars
+}
+
+// validateAliases checks that every alias resolves, as the certificate
+// can't be requested and clients can't be redirected otherwise
+func validateAliases(config *model.Config, lookupHost func(string) ([]string, error)) error {
+	for _, alias := range config.Aliases {
+		if _, err := lookupHost(alias); err != nil {
+			return fmt.Errorf("alias %q doesn't resolve: %w", alias, err)
+		}
+	}
+	return nil
+}
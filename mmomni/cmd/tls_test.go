@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
+)
+
+func TestValidateAliases(t *testing.T) {
+	lookupHost := func(host string) ([]string, error) {
+		if host == "chat.example.com" {
+			return []string{"10.0.0.1"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	config := &model.Config{FQDN: model.NewString("mattermost.example.com"), Aliases: []string{"chat.example.com"}}
+	config.SetDefaults()
+	require.NoError(t, validateAliases(config, lookupHost))
+
+	config.Aliases = append(config.Aliases, "missing.example.com")
+	err := validateAliases(config, lookupHost)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing.example.com")
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	crand "crypto/rand"
+	"fmt"
+	"math/big"
 	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/mattermost/mattermost-omnibus/mmomni/model"
 )
 
 const PasswdSize = 40
@@ -22,8 +27,43 @@ func CreatePGPassword() string {
 	return string(b)
 }
 
+// CreatePassword generates a random password using a cryptographically
+// secure source, for the passwords of Mattermost users
+func CreatePassword() (string, error) {
+	max := big.NewInt(int64(len(PasswdRunes)))
+	b := make([]rune, PasswdSize)
+	for i := range b {
+		n, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = PasswdRunes[n.Int64()]
+	}
+	return string(b), nil
+}
+
 func ParseFQDN(fqdn string) string {
 	fqdn = strings.TrimPrefix(fqdn, "http://")
 	fqdn = strings.TrimPrefix(fqdn, "https://")
 	return strings.Split(fqdn, "/")[0]
 }
+
+// ParseSubpath returns the URL path of a domain name, without the
+// trailing slash, so it can be used as the subpath
+func ParseSubpath(fqdn string) string {
+	fqdn = strings.TrimPrefix(fqdn, "http://")
+	fqdn = strings.TrimPrefix(fqdn, "https://")
+	parts := strings.SplitN(fqdn, "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimSuffix("/"+parts[1], "/")
+}
+
+// printConfigChanges reports the migrations that were applied to a
+// configuration when reading it from disk
+func printConfigChanges(config *model.Config) {
+	for _, change := range config.Changes() {
+		fmt.Printf("%s: %s\n", config.Path, change)
+	}
+}
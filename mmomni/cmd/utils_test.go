@@ -8,9 +8,10 @@ import (
 
 func TestParseFQDN(t *testing.T) {
 	testCases := []struct {
-		name         string
-		fqdn         string
-		expectedFQDN string
+		name            string
+		fqdn            string
+		expectedFQDN    string
+		expectedSubpath string
 	}{
 		{
 			name:         "A domain name should not be modified",
@@ -28,9 +29,16 @@ func TestParseFQDN(t *testing.T) {
 			expectedFQDN: "mattermost.example.com",
 		},
 		{
-			name:         "A domain name with an URL path",
-			fqdn:         "https://mattermost.example.com/chat",
-			expectedFQDN: "mattermost.example.com",
+			name:            "A domain name with an URL path",
+			fqdn:            "https://mattermost.example.com/chat",
+			expectedFQDN:    "mattermost.example.com",
+			expectedSubpath: "/chat",
+		},
+		{
+			name:            "A domain name with a nested URL path and a trailing slash",
+			fqdn:            "example.com/apps/chat/",
+			expectedFQDN:    "example.com",
+			expectedSubpath: "/apps/chat",
 		},
 	}
 
@@ -38,6 +46,17 @@ func TestParseFQDN(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			parsedFQDN := ParseFQDN(tc.fqdn)
 			require.Equal(t, tc.expectedFQDN, parsedFQDN)
+			require.Equal(t, tc.expectedSubpath, ParseSubpath(tc.fqdn))
 		})
 	}
 }
+
+func TestCreatePassword(t *testing.T) {
+	password, err := CreatePassword()
+	require.NoError(t, err)
+	require.Len(t, password, PasswdSize)
+
+	other, err := CreatePassword()
+	require.NoError(t, err)
+	require.NotEqual(t, password, other)
+}
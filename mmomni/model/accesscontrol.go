@@ -0,0 +1,153 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	CLIENT_VERIFY_ON       = "on"
+	CLIENT_VERIFY_OPTIONAL = "optional"
+)
+
+var headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// AccessControlConfig restricts the clients that can reach
+// Mattermost. Addresses in deny are rejected first, and if allow is
+// not empty, only the addresses in it are accepted. When the requests
+// come from a trusted proxy, the client address is read from the
+// real_ip_header
+type AccessControlConfig struct {
+	Allow          []string `yaml:"allow,omitempty"`
+	Deny           []string `yaml:"deny,omitempty"`
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+	RealIPHeader   *string  `yaml:"real_ip_header"`
+	ClientCA       *string  `yaml:"client_ca"`
+	ClientVerify   *string  `yaml:"client_verify"`
+}
+
+func (a *AccessControlConfig) SetDefaults() {
+	if a.RealIPHeader == nil {
+		a.RealIPHeader = NewString("X-Forwarded-For")
+	}
+
+	if a.ClientCA == nil {
+		a.ClientCA = NewString("")
+	}
+
+	if a.ClientVerify == nil {
+		a.ClientVerify = NewString(CLIENT_VERIFY_ON)
+	}
+}
+
+// isValidAddress checks that an address is an IP or a CIDR range
+func isValidAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(address)
+	return err == nil
+}
+
+func (a *AccessControlConfig) IsValid() error {
+	lists := []struct {
+		name      string
+		addresses []string
+	}{{"allow", a.Allow}, {"deny", a.Deny}, {"trusted_proxies", a.TrustedProxies}}
+	for _, list := range lists {
+		for _, address := range list.addresses {
+			if !isValidAddress(address) {
+				return fmt.Errorf("%s must contain IP addresses or CIDR ranges, found %q", list.name, address)
+			}
+		}
+	}
+
+	if !headerNameRegexp.MatchString(*a.RealIPHeader) {
+		return fmt.Errorf("invalid real_ip_header %q", *a.RealIPHeader)
+	}
+
+	if *a.ClientCA != "" && !filepath.IsAbs(*a.ClientCA) {
+		return fmt.Errorf("client_ca must be an absolute path")
+	}
+
+	if *a.ClientVerify != CLIENT_VERIFY_ON && *a.ClientVerify != CLIENT_VERIFY_OPTIONAL {
+		return fmt.Errorf("client_verify must be either %q or %q", CLIENT_VERIFY_ON, CLIENT_VERIFY_OPTIONAL)
+	}
+
+	return nil
+}
+
+// isValidAccessControl checks the access_control settings that depend
+// on the rest of the configuration
+func (c *Config) isValidAccessControl() error {
+	if err := c.AccessControl.IsValid(); err != nil {
+		return err
+	}
+
+	if *c.AccessControl.ClientCA != "" && !*c.HTTPS {
+		return fmt.Errorf("client_ca requires https to be enabled")
+	}
+
+	return nil
+}
+
+// realIPDirectives returns the directives that take the client address
+// from the requests of the trusted proxies of the access_control
+// section and of the load balancer
+func (c *Config) realIPDirectives() []string {
+	trusted := []string{}
+	header := "X-Forwarded-For"
+	if c.AccessControl != nil {
+		trusted = append(trusted, c.AccessControl.TrustedProxies...)
+		header = *c.AccessControl.RealIPHeader
+	}
+	if c.BehindLB() {
+		trusted = append(trusted, c.LoadBalancer.Addresses...)
+	}
+	if c.ProxyProtocol() {
+		header = "proxy_protocol"
+	}
+
+	directives := []string{}
+	if len(trusted) == 0 {
+		return directives
+	}
+
+	for _, address := range trusted {
+		directives = append(directives, "set_real_ip_from "+address+";")
+	}
+	return append(directives, "real_ip_header "+header+";", "real_ip_recursive on;")
+}
+
+// NginxAccessControlDirectives returns the NGINX directives of the
+// access_control section for the Mattermost server block. With the
+// optional client_verify mode, client certificates are verified when
+// presented but not required, so they can be enforced only for some
+// locations through $ssl_client_verify
+func (c *Config) NginxAccessControlDirectives() []string {
+	directives := c.realIPDirectives()
+	if c.AccessControl == nil {
+		return directives
+	}
+	a := c.AccessControl
+
+	for _, address := range a.Deny {
+		directives = append(directives, "deny "+address+";")
+	}
+
+	if len(a.Allow) != 0 {
+		for _, address := range a.Allow {
+			directives = append(directives, "allow "+address+";")
+		}
+		directives = append(directives, "deny all;")
+	}
+
+	if *a.ClientCA != "" {
+		directives = append(directives, "ssl_client_certificate "+*a.ClientCA+";")
+		directives = append(directives, "ssl_verify_client "+*a.ClientVerify+";")
+	}
+
+	return directives
+}
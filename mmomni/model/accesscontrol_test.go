@@ -0,0 +1,78 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessControl(t *testing.T) {
+	t.Run("No directives without access_control", func(t *testing.T) {
+		require.Empty(t, withDefaults(&Config{HTTPS: NewBool(true)}).NginxAccessControlDirectives())
+	})
+
+	t.Run("Directives", func(t *testing.T) {
+		c := withDefaults(&Config{HTTPS: NewBool(true), AccessControl: &AccessControlConfig{
+			Allow:          []string{"192.0.2.0/24", "2001:db8::/32"},
+			Deny:           []string{"192.0.2.13"},
+			TrustedProxies: []string{"10.0.0.0/8"},
+			ClientCA:       NewString("/etc/mattermost/client_ca.pem"),
+		}})
+		require.NoError(t, c.isValidAccessControl())
+		require.Equal(t, []string{
+			"set_real_ip_from 10.0.0.0/8;",
+			"real_ip_header X-Forwarded-For;",
+			"real_ip_recursive on;",
+			"deny 192.0.2.13;",
+			"allow 192.0.2.0/24;",
+			"allow 2001:db8::/32;",
+			"deny all;",
+			"ssl_client_certificate /etc/mattermost/client_ca.pem;",
+			"ssl_verify_client on;",
+		}, c.NginxAccessControlDirectives())
+	})
+
+	t.Run("Deny without allow accepts the rest", func(t *testing.T) {
+		c := withDefaults(&Config{HTTPS: NewBool(false), AccessControl: &AccessControlConfig{Deny: []string{"198.51.100.0/24"}}})
+		require.Equal(t, []string{"deny 198.51.100.0/24;"}, c.NginxAccessControlDirectives())
+	})
+
+	testCases := []struct {
+		Name          string
+		HTTPS         bool
+		AccessControl *AccessControlConfig
+	}{
+		{
+			Name:          "Invalid allow address",
+			AccessControl: &AccessControlConfig{Allow: []string{"office"}},
+		},
+		{
+			Name:          "Invalid trusted proxy range",
+			AccessControl: &AccessControlConfig{TrustedProxies: []string{"10.0.0.0/33"}},
+		},
+		{
+			Name:          "Invalid real_ip_header",
+			AccessControl: &AccessControlConfig{RealIPHeader: NewString("X-Real-IP;")},
+		},
+		{
+			Name:          "Relative client_ca",
+			HTTPS:         true,
+			AccessControl: &AccessControlConfig{ClientCA: NewString("client_ca.pem")},
+		},
+		{
+			Name:          "Invalid client_verify",
+			HTTPS:         true,
+			AccessControl: &AccessControlConfig{ClientCA: NewString("/etc/mattermost/client_ca.pem"), ClientVerify: NewString("off")},
+		},
+		{
+			Name:          "client_ca requires https",
+			AccessControl: &AccessControlConfig{ClientCA: NewString("/etc/mattermost/client_ca.pem")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Error(t, withDefaults(&Config{HTTPS: NewBool(tc.HTTPS), AccessControl: tc.AccessControl}).isValidAccessControl())
+		})
+	}
+}
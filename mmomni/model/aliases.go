@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var hostnameRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Hostnames returns the fqdn followed by its aliases
+func (c *Config) Hostnames() []string {
+	if *c.FQDN == "" {
+		return []string{}
+	}
+	return append([]string{*c.FQDN}, c.Aliases...)
+}
+
+func (c *Config) isValidAliases() error {
+	if len(c.Aliases) == 0 {
+		return nil
+	}
+
+	if *c.FQDN == "" {
+		return fmt.Errorf("fqdn must be set if aliases are set")
+	}
+
+	seen := map[string]bool{strings.ToLower(*c.FQDN): true}
+	for _, alias := range c.Aliases {
+		if !hostnameRegexp.MatchString(alias) {
+			return fmt.Errorf("alias %q is not a valid lowercase hostname", alias)
+		}
+
+		if seen[alias] {
+			return fmt.Errorf("alias %q is duplicated or equal to the fqdn", alias)
+		}
+		seen[alias] = true
+	}
+
+	return nil
+}
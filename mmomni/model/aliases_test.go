@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliases(t *testing.T) {
+	require.Empty(t, withDefaults(&Config{FQDN: NewString("")}).Hostnames())
+	require.Equal(t, []string{"mattermost.example.com", "chat.example.com"}, withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Aliases: []string{"chat.example.com"}}).Hostnames())
+
+	testCases := []struct {
+		Name        string
+		Config      *Config
+		ExpectError bool
+	}{
+		{
+			Name:   "No aliases is valid",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com")}),
+		},
+		{
+			Name:   "Valid aliases",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Aliases: []string{"chat.example.com", "mm.example.org"}}),
+		},
+		{
+			Name:        "Aliases require the fqdn",
+			Config:      withDefaults(&Config{FQDN: NewString(""), Aliases: []string{"chat.example.com"}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Aliases must be hostnames",
+			Config:      withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Aliases: []string{"https://chat.example.com"}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Aliases can't repeat the fqdn",
+			Config:      withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Aliases: []string{"mattermost.example.com"}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Aliases can't be duplicated",
+			Config:      withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Aliases: []string{"chat.example.com", "chat.example.com"}}),
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Config.isValidAliases()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"path/filepath"
+)
+
+const (
+	// BOOTSTRAP_DONE_PATH is written once the bootstrap section has
+	// been applied, so it is never applied again
+	BOOTSTRAP_DONE_PATH = "/etc/mattermost/mmomni.bootstrap.done"
+	// BOOTSTRAP_LOCK_PATH exists while the bootstrap is pending. NGINX
+	// rejects all the requests while it exists, so nobody can claim
+	// the first account of the server before the bootstrap admin
+	BOOTSTRAP_LOCK_PATH = "/etc/mattermost/mmomni.bootstrap.lock"
+
+	BOOTSTRAP_PASSWORD_FILENAME = "mmomni.admin_password"
+)
+
+// BootstrapConfig contains the first admin user and team of the
+// server, created by reconfigure the first time it runs. If the admin
+// password file doesn't exist, a random password is generated and
+// written to it
+type BootstrapConfig struct {
+	AdminUsername     *string `yaml:"admin_username"`
+	AdminEmail        *string `yaml:"admin_email"`
+	AdminPasswordFile *string `yaml:"admin_password_file"`
+	TeamName          *string `yaml:"team_name"`
+	TeamDisplayName   *string `yaml:"team_display_name"`
+}
+
+// defaultAdminPasswordPath returns the path of the admin password
+// file that sits next to the configuration file at path
+func defaultAdminPasswordPath(path string) string {
+	if path == "" {
+		path = CONFIGPATH
+	}
+	return filepath.Join(filepath.Dir(path), BOOTSTRAP_PASSWORD_FILENAME)
+}
+
+func (b *BootstrapConfig) SetDefaults() {
+	if b.AdminUsername == nil {
+		b.AdminUsername = NewString("")
+	}
+
+	if b.AdminEmail == nil {
+		b.AdminEmail = NewString("")
+	}
+
+	if b.TeamName == nil {
+		b.TeamName = NewString("")
+	}
+
+	if b.TeamDisplayName == nil {
+		b.TeamDisplayName = NewString("")
+	}
+}
+
+func (b *BootstrapConfig) IsValid() error {
+	if *b.AdminUsername == "" {
+		return fmt.Errorf("admin_username cannot be empty")
+	}
+
+	if _, err := mail.ParseAddress(*b.AdminEmail); err != nil {
+		return fmt.Errorf("invalid admin_email %q: %w", *b.AdminEmail, err)
+	}
+
+	if !filepath.IsAbs(*b.AdminPasswordFile) {
+		return fmt.Errorf("admin_password_file must be an absolute path")
+	}
+
+	if *b.TeamName == "" && *b.TeamDisplayName != "" {
+		return fmt.Errorf("team_name must be set if team_display_name is set")
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapConfig(t *testing.T) {
+	t.Run("The admin password file should default to the configuration directory", func(t *testing.T) {
+		c := &Config{Path: "/tmp/mmomni/mmomni.yml", Bootstrap: &BootstrapConfig{}}
+		c.SetDefaults()
+		require.Equal(t, "/tmp/mmomni/"+BOOTSTRAP_PASSWORD_FILENAME, *c.Bootstrap.AdminPasswordFile)
+	})
+
+	t.Run("The bootstrap section should require the local mode", func(t *testing.T) {
+		c := &Config{Path: CONFIGPATH, EnableLocalMode: NewBool(false), Bootstrap: &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin@example.com")}}
+		c.SetDefaults()
+		err := c.IsValid()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "enable_local_mode")
+
+		c.EnableLocalMode = NewBool(true)
+		require.NoError(t, c.IsValid())
+	})
+
+	testCases := []struct {
+		Name        string
+		Bootstrap   *BootstrapConfig
+		ExpectError bool
+	}{
+		{
+			Name:      "Admin without team is valid",
+			Bootstrap: &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin@example.com")},
+		},
+		{
+			Name:      "Admin with team is valid",
+			Bootstrap: &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin@example.com"), TeamName: NewString("myteam")},
+		},
+		{
+			Name:        "Admin username is required",
+			Bootstrap:   &BootstrapConfig{AdminEmail: NewString("admin@example.com")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Admin email must be valid",
+			Bootstrap:   &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Admin password file must be absolute",
+			Bootstrap:   &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin@example.com"), AdminPasswordFile: NewString("password")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Team display name requires team name",
+			Bootstrap:   &BootstrapConfig{AdminUsername: NewString("admin"), AdminEmail: NewString("admin@example.com"), TeamDisplayName: NewString("My Team")},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := &Config{Path: CONFIGPATH, Bootstrap: tc.Bootstrap}
+			c.SetDefaults()
+			err := c.Bootstrap.IsValid()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
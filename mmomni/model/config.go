@@ -19,8 +19,20 @@ const (
 type Config struct {
 	Path string `yaml:"-"`
 
+	// node holds the document read from disk, so its comments, key
+	// ordering and unknown keys can be preserved when writing the
+	// configuration back
+	node *yaml.Node
+	// changes holds the description of the migrations applied to the
+	// configuration when it was read
+	changes []string
+
+	ConfigVersion *int `yaml:"config_version"`
+
+	SecretsFile *string `yaml:"secrets_file"`
+
 	DBUser              *string `yaml:"db_user"`
-	DBPassword          *string `yaml:"db_password"`
+	DBPassword          *string `yaml:"db_password,omitempty"` // stored in the secrets file
 	FQDN                *string `yaml:"fqdn"`
 	Email               *string `yaml:"email"`
 	HTTPS               *bool   `yaml:"https"`
@@ -28,8 +40,59 @@ type Config struct {
 	EnablePluginUploads *bool   `yaml:"enable_plugin_uploads"`
 	EnableLocalMode     *bool   `yaml:"enable_local_mode"`
 	ClientMaxBodySize   *string `yaml:"client_max_body_size"`
+	HTTPPort            *int    `yaml:"http_port"`
+	HTTPSPort           *int    `yaml:"https_port"`
+	MattermostListen    *string `yaml:"mattermost_listen"`
+	Subpath             *string `yaml:"subpath"`
+
+	// BindAddresses are the IP addresses that NGINX listens on. If
+	// empty, NGINX listens on all the addresses
+	BindAddresses []string `yaml:"bind_addresses,omitempty"`
+
+	// Aliases are additional hostnames that are covered by the
+	// certificate and redirect to the fqdn
+	Aliases []string `yaml:"aliases,omitempty"`
 
 	NginxTemplate *string `yaml:"nginx_template,omitempty"`
+
+	// ProxyMode is behind_lb when an external load balancer
+	// terminates TLS and forwards the requests to Omnibus
+	ProxyMode    *string             `yaml:"proxy_mode,omitempty"`
+	LoadBalancer *LoadBalancerConfig `yaml:"load_balancer,omitempty"`
+
+	// Nginx customizes the stock NGINX template, so it doesn't need to
+	// be replaced through nginx_template
+	Nginx *NginxConfig `yaml:"nginx,omitempty"`
+
+	// TLS configures the certificate used when https is enabled
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+	// TLSPolicy configures the protocols, ciphers, OCSP stapling and
+	// HSTS header of NGINX when https is enabled
+	TLSPolicy *TLSPolicyConfig `yaml:"tls_policy,omitempty"`
+	// AccessControl restricts the client addresses and certificates
+	// accepted by NGINX
+	AccessControl *AccessControlConfig `yaml:"access_control,omitempty"`
+
+	SMTP        *SMTPConfig        `yaml:"smtp,omitempty"`
+	FileStorage *FileStorageConfig `yaml:"file_storage,omitempty"`
+
+	// Database contains the connection settings of the database, which
+	// can be an external server not managed by Omnibus
+	Database *DatabaseConfig `yaml:"database,omitempty"`
+	// Postgres tunes the local PostgreSQL cluster and the Mattermost
+	// connection pool
+	Postgres *PostgresConfig `yaml:"postgres,omitempty"`
+
+	// MattermostSettings contains Mattermost settings that are set
+	// through environment variables, following the structure of the
+	// Mattermost configuration, e.g. EmailSettings.SMTPServer
+	MattermostSettings map[string]interface{} `yaml:"mattermost_settings,omitempty"`
+
+	// Plugins is the list of plugins that reconfigure installs and
+	// enables. If set, the plugins that are not listed are disabled
+	Plugins []*PluginConfig `yaml:"plugins,omitempty"`
+
+	Bootstrap *BootstrapConfig `yaml:"bootstrap,omitempty"`
 }
 
 func ReadConfig(path string) (*Config, error) {
@@ -42,12 +105,42 @@ func ReadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
-	config := &Config{Path: path}
-	if err := yaml.Unmarshal(fileBytes, config); err != nil {
+	var node yaml.Node
+	if err := yaml.Unmarshal(fileBytes, &node); err != nil {
 		return nil, err
 	}
 
+	config := &Config{Path: path}
+	if node.Kind == yaml.DocumentNode {
+		migratedSecrets := &Secrets{}
+		changes, err := migrateConfig(&node, configMigrations, migratedSecrets)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := node.Decode(config); err != nil {
+			return nil, err
+		}
+		config.node = &node
+		config.changes = changes
+
+		// the secrets file takes precedence over the migrated values
+		if migratedSecrets.DBPassword != nil {
+			config.DBPassword = migratedSecrets.DBPassword
+		}
+	}
+
+	// secrets stored in the configuration file by older Omnibus
+	// versions are moved to the secrets file on the next save
+	for _, key := range config.inlineSecrets() {
+		config.changes = append(config.changes, key+" will be moved to the secrets file")
+	}
+
 	config.SetDefaults()
+	if err := config.LoadSecrets(*config.SecretsFile); err != nil {
+		return nil, fmt.Errorf("cannot read secrets file: %w", err)
+	}
+
 	if err := config.IsValid(); err != nil {
 		return nil, err
 	}
@@ -55,7 +148,21 @@ func ReadConfig(path string) (*Config, error) {
 	return config, nil
 }
 
+// Changes returns the description of the migrations applied to the
+// configuration when it was read from disk
+func (c *Config) Changes() []string {
+	return c.changes
+}
+
 func (c *Config) SetDefaults() {
+	if c.ConfigVersion == nil {
+		c.ConfigVersion = NewInt(CONFIG_VERSION)
+	}
+
+	if c.SecretsFile == nil {
+		c.SecretsFile = NewString(defaultSecretsPath(c.Path))
+	}
+
 	if c.DBUser == nil {
 		c.DBUser = NewString(DBUSER)
 	}
@@ -92,9 +199,72 @@ func (c *Config) SetDefaults() {
 		c.ClientMaxBodySize = NewString("50M")
 	}
 
+	if c.HTTPPort == nil {
+		c.HTTPPort = NewInt(80)
+	}
+
+	if c.HTTPSPort == nil {
+		c.HTTPSPort = NewInt(443)
+	}
+
+	if c.MattermostListen == nil {
+		c.MattermostListen = NewString("127.0.0.1:8065")
+	}
+
+	if c.Subpath == nil {
+		c.Subpath = NewString("")
+	}
+
 	if c.NginxTemplate == nil {
 		c.NginxTemplate = NewString("")
 	}
+
+	if c.ProxyMode == nil {
+		c.ProxyMode = NewString(PROXY_MODE_DIRECT)
+	}
+
+	if c.LoadBalancer != nil {
+		c.LoadBalancer.SetDefaults()
+	}
+
+	if c.Nginx != nil {
+		c.Nginx.SetDefaults()
+	}
+
+	if c.TLS != nil {
+		c.TLS.SetDefaults()
+	}
+
+	if c.TLSPolicy != nil {
+		c.TLSPolicy.SetDefaults()
+	}
+
+	if c.AccessControl != nil {
+		c.AccessControl.SetDefaults()
+	}
+
+	if c.SMTP != nil {
+		c.SMTP.SetDefaults()
+	}
+
+	if c.FileStorage != nil {
+		c.FileStorage.SetDefaults()
+	}
+
+	if c.Database != nil {
+		c.Database.SetDefaults()
+	}
+
+	if c.Postgres != nil {
+		c.Postgres.SetDefaults()
+	}
+
+	if c.Bootstrap != nil {
+		c.Bootstrap.SetDefaults()
+		if c.Bootstrap.AdminPasswordFile == nil {
+			c.Bootstrap.AdminPasswordFile = NewString(defaultAdminPasswordPath(c.Path))
+		}
+	}
 }
 
 func (c *Config) Clone() (*Config, error) {
@@ -124,6 +294,22 @@ func (c *Config) PreSave() (*Config, error) {
 		cfg.NginxTemplate = nil
 	}
 
+	if cfg.ProxyMode != nil && *cfg.ProxyMode == PROXY_MODE_DIRECT {
+		cfg.ProxyMode = nil
+	}
+
+	// secrets are written to their own file
+	cfg.DBPassword = nil
+	if cfg.SMTP != nil {
+		cfg.SMTP.Password = nil
+	}
+	if cfg.FileStorage != nil && cfg.FileStorage.S3 != nil {
+		cfg.FileStorage.S3.SecretAccessKey = nil
+	}
+	if cfg.TLS != nil && cfg.TLS.ACME != nil {
+		cfg.TLS.ACME.EABHMACKey = nil
+	}
+
 	return cfg, nil
 }
 
@@ -132,14 +318,106 @@ func (c *Config) IsValid() error {
 		return fmt.Errorf("database user cannot be empty")
 	}
 
-	if *c.HTTPS && (*c.FQDN == "" || *c.Email == "") {
-		return fmt.Errorf("fqdn and email must be set if https is enabled")
+	if err := c.isValidAliases(); err != nil {
+		return err
+	}
+
+	if *c.HTTPS && *c.FQDN == "" {
+		return fmt.Errorf("fqdn must be set if https is enabled")
+	}
+
+	if *c.HTTPS && c.TLSMode() == TLS_MODE_LETSENCRYPT && *c.Email == "" {
+		return fmt.Errorf("email must be set if https is enabled with the %q tls mode", TLS_MODE_LETSENCRYPT)
+	}
+
+	if err := c.isValidProxyMode(); err != nil {
+		return err
+	}
+
+	if err := c.isValidListen(); err != nil {
+		return err
+	}
+
+	if err := c.isValidSubpath(); err != nil {
+		return err
 	}
 
 	if *c.DataDirectory == "" {
 		return fmt.Errorf("data_directory cannot be empty")
 	}
 
+	if *c.SecretsFile == "" {
+		return fmt.Errorf("secrets_file cannot be empty")
+	}
+
+	if c.Nginx != nil {
+		if err := c.Nginx.IsValid(); err != nil {
+			return fmt.Errorf("invalid nginx configuration: %w", err)
+		}
+	}
+
+	if c.TLS != nil {
+		if err := c.TLS.IsValid(); err != nil {
+			return fmt.Errorf("invalid tls configuration: %w", err)
+		}
+	}
+
+	if c.TLSPolicy != nil {
+		if err := c.TLSPolicy.IsValid(); err != nil {
+			return fmt.Errorf("invalid tls_policy configuration: %w", err)
+		}
+	}
+
+	if c.AccessControl != nil {
+		if err := c.isValidAccessControl(); err != nil {
+			return fmt.Errorf("invalid access_control configuration: %w", err)
+		}
+	}
+
+	if c.SMTP != nil {
+		if err := c.SMTP.IsValid(); err != nil {
+			return fmt.Errorf("invalid smtp configuration: %w", err)
+		}
+	}
+
+	if c.FileStorage != nil {
+		if err := c.FileStorage.IsValid(); err != nil {
+			return fmt.Errorf("invalid file_storage configuration: %w", err)
+		}
+	}
+
+	if c.Database != nil {
+		if err := c.isValidDatabase(); err != nil {
+			return fmt.Errorf("invalid database configuration: %w", err)
+		}
+	}
+
+	if c.Postgres != nil {
+		if err := c.Postgres.IsValid(); err != nil {
+			return fmt.Errorf("invalid postgres configuration: %w", err)
+		}
+	}
+
+	if err := c.isValidMattermostSettings(); err != nil {
+		return err
+	}
+
+	if c.Bootstrap != nil {
+		if err := c.Bootstrap.IsValid(); err != nil {
+			return fmt.Errorf("invalid bootstrap configuration: %w", err)
+		}
+
+		// the bootstrap is applied through the local mode, and NGINX
+		// rejects all the requests until it succeeds
+		if !*c.EnableLocalMode {
+			return fmt.Errorf("enable_local_mode needs to be true to apply the bootstrap section")
+		}
+	}
+
+	if err := c.isValidPlugins(); err != nil {
+		return fmt.Errorf("invalid plugins configuration: %w", err)
+	}
+
 	return nil
 }
 
@@ -153,12 +431,22 @@ func (c *Config) WriteToDisk() error {
 		return err
 	}
 
-	configBytes, err := yaml.Marshal(cfg)
+	configBytes, err := cfg.marshal(c.node)
 	if err != nil {
 		return err
 	}
 
-	if err := ioutil.WriteFile(c.Path, configBytes, 0640); err != nil {
+	// secrets are written first, so they are never lost if the
+	// configuration file is updated and the secrets file is not
+	if err := c.writeSecrets(); err != nil {
+		return fmt.Errorf("cannot write secrets file: %w", err)
+	}
+
+	if err := archiveConfig(c.Path, configBytes); err != nil {
+		return fmt.Errorf("cannot store previous configuration in history: %w", err)
+	}
+
+	if err := writeFileAtomic(c.Path, configBytes, 0640); err != nil {
 		return err
 	}
 
@@ -1,11 +1,21 @@
 package model
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// withDefaults sets the defaults of a config built by a test.
+func withDefaults(c *Config) *Config {
+	c.SetDefaults()
+	return c
+}
+
 func TestConfigPreSave(t *testing.T) {
 	t.Run("nginx_template pointer should be nil after PreSave if its empty string", func(t *testing.T) {
 		baseConfig := &Config{
@@ -22,4 +32,88 @@ func TestConfigPreSave(t *testing.T) {
 		require.NoError(t, err)
 		require.Nil(t, cfg.NginxTemplate)
 	})
+
+	t.Run("proxy_mode pointer should be nil after PreSave if it's the direct mode", func(t *testing.T) {
+		baseConfig := &Config{NginxTemplate: NewString(""), ProxyMode: NewString(PROXY_MODE_BEHIND_LB)}
+
+		cfg, err := baseConfig.PreSave()
+		require.NoError(t, err)
+		require.Equal(t, PROXY_MODE_BEHIND_LB, *cfg.ProxyMode)
+
+		baseConfig.ProxyMode = NewString(PROXY_MODE_DIRECT)
+		cfg, err = baseConfig.PreSave()
+		require.NoError(t, err)
+		require.Nil(t, cfg.ProxyMode)
+	})
+}
+
+func TestConfigWriteToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("comments, key ordering and unknown keys should be preserved", func(t *testing.T) {
+		path := filepath.Join(dir, "mmomni.yml")
+		original := `# Managed by the ops team
+fqdn: mattermost.example.com # the public name
+email: ops@example.com
+
+# database credentials
+db_user: mmuser
+db_password: secret
+https: true
+custom_key: keep me
+client_max_body_size: "50M"
+`
+		require.NoError(t, ioutil.WriteFile(path, []byte(original), 0640))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+
+		config.FQDN = NewString("chat.example.com")
+		require.NoError(t, config.WriteToDisk())
+
+		fileBytes, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		contents := string(fileBytes)
+
+		require.Contains(t, contents, "# Managed by the ops team\n")
+		require.Contains(t, contents, "fqdn: chat.example.com # the public name\n")
+		require.Contains(t, contents, "# database credentials\ndb_user: mmuser\n")
+		require.Contains(t, contents, "custom_key: keep me\n")
+		require.Contains(t, contents, "client_max_body_size: \"50M\"\n")
+		require.NotContains(t, contents, "nginx_template")
+		require.True(t, strings.Index(contents, "fqdn:") < strings.Index(contents, "db_user:"))
+
+		// defaults that were missing are appended at the end
+		require.Contains(t, contents, "data_directory: "+DATADIRECTORY)
+		require.True(t, strings.Index(contents, "data_directory:") > strings.Index(contents, "custom_key:"))
+	})
+
+	t.Run("optional keys should be removed if they become empty", func(t *testing.T) {
+		path := filepath.Join(dir, "mmomni_template.yml")
+		require.NoError(t, ioutil.WriteFile(path, []byte("db_user: mmuser\nnginx_template: /some/path.conf\n"), 0640))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+
+		config.NginxTemplate = NewString("")
+		require.NoError(t, config.WriteToDisk())
+
+		fileBytes, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(fileBytes), "nginx_template")
+	})
+
+	t.Run("a config without a source document should be written in full", func(t *testing.T) {
+		path := filepath.Join(dir, "new", "mmomni.yml")
+		config := &Config{Path: path}
+		config.SetDefaults()
+		require.NoError(t, config.WriteToDisk())
+
+		readConfig, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, *config.DBUser, *readConfig.DBUser)
+		require.Equal(t, *config.DataDirectory, *readConfig.DataDirectory)
+	})
 }
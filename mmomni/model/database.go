@@ -0,0 +1,241 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var databaseSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// DatabaseConfig contains the connection settings of the Mattermost
+// database. When the database is external, Omnibus doesn't manage the
+// local PostgreSQL server. Read replicas are host or host:port
+// addresses that share the credentials, name and TLS settings of the
+// primary
+type DatabaseConfig struct {
+	External     *bool    `yaml:"external"`
+	Host         *string  `yaml:"host"`
+	Port         *int     `yaml:"port"`
+	Name         *string  `yaml:"name"`
+	SSLMode      *string  `yaml:"sslmode"`
+	SSLRootCert  *string  `yaml:"ssl_root_cert"`
+	ReadReplicas []string `yaml:"read_replicas,omitempty"`
+}
+
+func (d *DatabaseConfig) SetDefaults() {
+	if d.External == nil {
+		d.External = NewBool(false)
+	}
+
+	if d.Host == nil {
+		d.Host = NewString("localhost")
+	}
+
+	if d.Port == nil {
+		d.Port = NewInt(5432)
+	}
+
+	if d.Name == nil {
+		d.Name = NewString("mattermost")
+	}
+
+	if d.SSLMode == nil {
+		d.SSLMode = NewString("disable")
+	}
+
+	if d.SSLRootCert == nil {
+		d.SSLRootCert = NewString("")
+	}
+}
+
+// replicaAddress returns the host and port of a read replica, using
+// the port of the primary if it's not set
+func (d *DatabaseConfig) replicaAddress(replica string) (string, int, error) {
+	if !strings.Contains(replica, ":") || (strings.HasPrefix(replica, "[") && strings.HasSuffix(replica, "]")) {
+		return strings.Trim(replica, "[]"), *d.Port, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(replica)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || !isValidPort(port) {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	return host, port, nil
+}
+
+func (d *DatabaseConfig) IsValid() error {
+	if *d.Host == "" || strings.ContainsAny(*d.Host, "/@?# ") {
+		return fmt.Errorf("host must be a hostname or an IP address")
+	}
+
+	// the local server is set up by Omnibus, so a remote host is only
+	// allowed for external databases
+	if !*d.External && !isLocalHost(*d.Host) {
+		return fmt.Errorf("host must be a local address unless external is enabled")
+	}
+
+	if !isValidPort(*d.Port) {
+		return fmt.Errorf("port must be a valid TCP port")
+	}
+
+	if *d.Name == "" || strings.ContainsAny(*d.Name, "/?# ") {
+		return fmt.Errorf("invalid database name %q", *d.Name)
+	}
+
+	valid := false
+	for _, mode := range databaseSSLModes {
+		valid = valid || *d.SSLMode == mode
+	}
+	if !valid {
+		return fmt.Errorf("sslmode must be one of %s", strings.Join(databaseSSLModes, ", "))
+	}
+
+	if *d.SSLRootCert != "" && !filepath.IsAbs(*d.SSLRootCert) {
+		return fmt.Errorf("ssl_root_cert must be an absolute path")
+	}
+
+	if *d.SSLMode == "verify-ca" || *d.SSLMode == "verify-full" {
+		if *d.SSLRootCert == "" {
+			return fmt.Errorf("ssl_root_cert must be set with the %q sslmode", *d.SSLMode)
+		}
+	}
+
+	for _, replica := range d.ReadReplicas {
+		host, _, err := d.replicaAddress(replica)
+		if err != nil || host == "" || strings.ContainsAny(host, "/@?# ") {
+			return fmt.Errorf("invalid read replica %q, it must be a host or a host:port address", replica)
+		}
+	}
+
+	return nil
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// DatabaseSettings returns the database section, or its defaults if
+// the section is not present
+func (c *Config) DatabaseSettings() *DatabaseConfig {
+	if c.Database != nil {
+		return c.Database
+	}
+
+	d := &DatabaseConfig{}
+	d.SetDefaults()
+	return d
+}
+
+// ExternalDatabase returns true if the database is not managed by
+// Omnibus
+func (c *Config) ExternalDatabase() bool {
+	return *c.DatabaseSettings().External
+}
+
+// isValidDatabase checks the database settings that depend on the
+// rest of the configuration
+func (c *Config) isValidDatabase() error {
+	if err := c.Database.IsValid(); err != nil {
+		return err
+	}
+
+	if *c.Database.External && c.Postgres != nil {
+		return fmt.Errorf("the postgres section can only be used with the local database, the connection pool of an external database can be set through mattermost_settings")
+	}
+
+	return nil
+}
+
+// dataSource returns the Mattermost data source of a database server
+func (c *Config) dataSource(host string, port int) string {
+	d := c.DatabaseSettings()
+
+	password := ""
+	if c.DBPassword != nil {
+		password = *c.DBPassword
+	}
+
+	dataSource := fmt.Sprintf("postgres://%s@%s/%s?sslmode=%s&connect_timeout=10",
+		url.UserPassword(*c.DBUser, password).String(),
+		net.JoinHostPort(host, strconv.Itoa(port)),
+		url.PathEscape(*d.Name),
+		*d.SSLMode,
+	)
+	if *d.SSLRootCert != "" {
+		dataSource += "&sslrootcert=" + url.QueryEscape(*d.SSLRootCert)
+	}
+	return dataSource
+}
+
+// DataSource returns the Mattermost data source of the primary
+// database
+func (c *Config) DataSource() string {
+	d := c.DatabaseSettings()
+	return c.dataSource(*d.Host, *d.Port)
+}
+
+// DatabaseEnv returns the environment variables with the Mattermost
+// data sources
+func (c *Config) DatabaseEnv() []*EnvVariable {
+	env := []*EnvVariable{
+		{Name: "MM_CONFIG", Value: quoteEnvValue(c.DataSource())},
+		{Name: "MM_SQLSETTINGS_DATASOURCE", Value: quoteEnvValue(c.DataSource())},
+	}
+
+	d := c.DatabaseSettings()
+	if len(d.ReadReplicas) != 0 {
+		replicas := []string{}
+		for _, replica := range d.ReadReplicas {
+			host, port, _ := d.replicaAddress(replica)
+			replicas = append(replicas, c.dataSource(host, port))
+		}
+		// list settings are read from the environment as space
+		// separated values
+		env = append(env, &EnvVariable{Name: "MM_SQLSETTINGS_DATASOURCEREPLICAS", Value: quoteEnvValue(strings.Join(replicas, " "))})
+	}
+
+	return env
+}
+
+// PostgresClientEnv returns the environment variables that connect the
+// PostgreSQL client tools to the database
+func (c *Config) PostgresClientEnv() []string {
+	d := c.DatabaseSettings()
+
+	password := ""
+	if c.DBPassword != nil {
+		password = *c.DBPassword
+	}
+
+	env := []string{
+		"PGHOST=" + *d.Host,
+		"PGPORT=" + strconv.Itoa(*d.Port),
+		"PGDATABASE=" + *d.Name,
+		"PGUSER=" + *c.DBUser,
+		"PGPASSWORD=" + password,
+		"PGSSLMODE=" + *d.SSLMode,
+	}
+	if *d.SSLRootCert != "" {
+		env = append(env, "PGSSLROOTCERT="+*d.SSLRootCert)
+	}
+	return env
+}
+
+func (d *DatabaseConfig) managedSettings() []string {
+	if len(d.ReadReplicas) == 0 {
+		return []string{}
+	}
+	return []string{"SqlSettings.DataSourceReplicas"}
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	FILE_STORAGE_DRIVER_LOCAL = "local"
+	FILE_STORAGE_DRIVER_S3    = "s3"
+)
+
+// FileStorageConfig contains the settings of the backend where
+// Mattermost stores attachments and plugins. The local driver uses the
+// data_directory
+type FileStorageConfig struct {
+	Driver *string   `yaml:"driver"`
+	S3     *S3Config `yaml:"s3,omitempty"`
+}
+
+// S3Config contains the settings of an S3 compatible bucket. The
+// secret access key is stored in the secrets file
+type S3Config struct {
+	Endpoint        *string `yaml:"endpoint"`
+	Bucket          *string `yaml:"bucket"`
+	Region          *string `yaml:"region"`
+	PathPrefix      *string `yaml:"path_prefix"`
+	AccessKeyID     *string `yaml:"access_key_id"`
+	SecretAccessKey *string `yaml:"secret_access_key,omitempty"`
+	SSL             *bool   `yaml:"ssl"`
+	SSE             *bool   `yaml:"sse"`
+}
+
+func (f *FileStorageConfig) SetDefaults() {
+	if f.Driver == nil {
+		f.Driver = NewString(FILE_STORAGE_DRIVER_LOCAL)
+	}
+
+	if f.S3 != nil {
+		f.S3.SetDefaults()
+	}
+}
+
+func (f *FileStorageConfig) IsValid() error {
+	switch *f.Driver {
+	case FILE_STORAGE_DRIVER_LOCAL:
+	case FILE_STORAGE_DRIVER_S3:
+		if f.S3 == nil {
+			return fmt.Errorf("s3 section must be set if driver is %q", FILE_STORAGE_DRIVER_S3)
+		}
+	default:
+		return fmt.Errorf("driver must be one of %q or %q", FILE_STORAGE_DRIVER_LOCAL, FILE_STORAGE_DRIVER_S3)
+	}
+
+	if f.S3 != nil {
+		if err := f.S3.IsValid(); err != nil {
+			return fmt.Errorf("invalid s3 configuration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// IsS3 returns true if files are stored in an S3 bucket
+func (f *FileStorageConfig) IsS3() bool {
+	return f != nil && f.Driver != nil && *f.Driver == FILE_STORAGE_DRIVER_S3
+}
+
+// Env returns the environment variables that configure the Mattermost
+// file storage backend
+func (f *FileStorageConfig) Env() []*EnvVariable {
+	if !f.IsS3() {
+		return []*EnvVariable{
+			{Name: "MM_FILESETTINGS_DRIVERNAME", Value: quoteEnvValue("local")},
+		}
+	}
+
+	s := f.S3
+	secretAccessKey := ""
+	if s.SecretAccessKey != nil {
+		secretAccessKey = *s.SecretAccessKey
+	}
+
+	values := []struct{ name, value string }{
+		{"MM_FILESETTINGS_DRIVERNAME", "amazons3"},
+		{"MM_FILESETTINGS_AMAZONS3ENDPOINT", *s.Endpoint},
+		{"MM_FILESETTINGS_AMAZONS3BUCKET", *s.Bucket},
+		{"MM_FILESETTINGS_AMAZONS3REGION", *s.Region},
+		{"MM_FILESETTINGS_AMAZONS3PATHPREFIX", *s.PathPrefix},
+		{"MM_FILESETTINGS_AMAZONS3ACCESSKEYID", *s.AccessKeyID},
+		{"MM_FILESETTINGS_AMAZONS3SECRETACCESSKEY", secretAccessKey},
+		{"MM_FILESETTINGS_AMAZONS3SSL", strconv.FormatBool(*s.SSL)},
+		{"MM_FILESETTINGS_AMAZONS3SSE", strconv.FormatBool(*s.SSE)},
+	}
+
+	env := make([]*EnvVariable, len(values))
+	for i, v := range values {
+		env[i] = &EnvVariable{Name: v.name, Value: quoteEnvValue(v.value)}
+	}
+
+	return env
+}
+
+// managedSettings returns the Mattermost settings that the file
+// storage configuration sets
+func (f *FileStorageConfig) managedSettings() []string {
+	settings := []string{"FileSettings.DriverName"}
+	if f.IsS3() {
+		settings = append(settings,
+			"FileSettings.AmazonS3Endpoint",
+			"FileSettings.AmazonS3Bucket",
+			"FileSettings.AmazonS3Region",
+			"FileSettings.AmazonS3PathPrefix",
+			"FileSettings.AmazonS3AccessKeyId",
+			"FileSettings.AmazonS3SecretAccessKey",
+			"FileSettings.AmazonS3SSL",
+			"FileSettings.AmazonS3SSE",
+		)
+	}
+	return settings
+}
+
+func (s *S3Config) SetDefaults() {
+	if s.Endpoint == nil {
+		s.Endpoint = NewString("s3.amazonaws.com")
+	}
+
+	if s.Bucket == nil {
+		s.Bucket = NewString("")
+	}
+
+	if s.Region == nil {
+		s.Region = NewString("us-east-1")
+	}
+
+	if s.PathPrefix == nil {
+		s.PathPrefix = NewString("")
+	}
+
+	if s.AccessKeyID == nil {
+		s.AccessKeyID = NewString("")
+	}
+
+	if s.SSL == nil {
+		s.SSL = NewBool(true)
+	}
+
+	if s.SSE == nil {
+		s.SSE = NewBool(false)
+	}
+}
+
+func (s *S3Config) IsValid() error {
+	if *s.Endpoint == "" {
+		return fmt.Errorf("endpoint cannot be empty")
+	}
+
+	if strings.Contains(*s.Endpoint, "://") || strings.Contains(*s.Endpoint, "/") {
+		return fmt.Errorf("endpoint must be a host name with an optional port, without scheme or path")
+	}
+
+	if *s.Bucket == "" {
+		return fmt.Errorf("bucket cannot be empty")
+	}
+
+	if *s.Region == "" {
+		return fmt.Errorf("region cannot be empty")
+	}
+
+	hasAccessKey := *s.AccessKeyID != ""
+	hasSecret := s.SecretAccessKey != nil && *s.SecretAccessKey != ""
+	if hasAccessKey != hasSecret {
+		return fmt.Errorf("access_key_id and secret_access_key must be set together")
+	}
+
+	return nil
+}
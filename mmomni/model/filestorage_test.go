@@ -0,0 +1,71 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorageConfig(t *testing.T) {
+	newS3Config := func() *FileStorageConfig {
+		f := &FileStorageConfig{
+			Driver: NewString(FILE_STORAGE_DRIVER_S3),
+			S3: &S3Config{
+				Endpoint:        NewString("minio.example.com:9000"),
+				Bucket:          NewString("mattermost"),
+				AccessKeyID:     NewString("access"),
+				SecretAccessKey: NewString("secret"),
+			},
+		}
+		f.SetDefaults()
+		return f
+	}
+
+	t.Run("The local driver should be valid without s3 settings", func(t *testing.T) {
+		f := &FileStorageConfig{}
+		f.SetDefaults()
+		require.NoError(t, f.IsValid())
+		require.Equal(t, []*EnvVariable{{Name: "MM_FILESETTINGS_DRIVERNAME", Value: `"local"`}}, f.Env())
+	})
+
+	t.Run("The s3 driver requires the s3 section", func(t *testing.T) {
+		f := &FileStorageConfig{Driver: NewString(FILE_STORAGE_DRIVER_S3)}
+		require.Error(t, f.IsValid())
+	})
+
+	t.Run("Unknown drivers should be rejected", func(t *testing.T) {
+		f := &FileStorageConfig{Driver: NewString("gcs")}
+		require.Error(t, f.IsValid())
+	})
+
+	t.Run("Endpoints with scheme should be rejected", func(t *testing.T) {
+		f := newS3Config()
+		f.S3.Endpoint = NewString("https://minio.example.com")
+		require.Error(t, f.IsValid())
+	})
+
+	t.Run("Credentials should be set together", func(t *testing.T) {
+		f := newS3Config()
+		f.S3.SecretAccessKey = nil
+		require.Error(t, f.IsValid())
+
+		f.S3.AccessKeyID = NewString("")
+		require.NoError(t, f.IsValid(), "empty credentials allow using instance roles")
+	})
+
+	t.Run("The s3 driver should generate the amazons3 settings", func(t *testing.T) {
+		f := newS3Config()
+		require.NoError(t, f.IsValid())
+
+		env := map[string]string{}
+		for _, variable := range f.Env() {
+			env[variable.Name] = variable.Value
+		}
+		require.Equal(t, `"amazons3"`, env["MM_FILESETTINGS_DRIVERNAME"])
+		require.Equal(t, `"minio.example.com:9000"`, env["MM_FILESETTINGS_AMAZONS3ENDPOINT"])
+		require.Equal(t, `"mattermost"`, env["MM_FILESETTINGS_AMAZONS3BUCKET"])
+		require.Equal(t, `"us-east-1"`, env["MM_FILESETTINGS_AMAZONS3REGION"])
+		require.Equal(t, `"secret"`, env["MM_FILESETTINGS_AMAZONS3SECRETACCESSKEY"])
+		require.Equal(t, `"true"`, env["MM_FILESETTINGS_AMAZONS3SSL"])
+	})
+}
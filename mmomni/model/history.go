@@ -0,0 +1,197 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// CONFIG_HISTORY_SIZE is the number of previous configuration
+	// files that are kept in the history directory
+	CONFIG_HISTORY_SIZE = 10
+
+	historyTimeFormat = "20060102_150405.000000000"
+)
+
+type HistoryEntry struct {
+	Path string
+	Time time.Time
+}
+
+// HistoryDir returns the directory where the previous versions of the
+// configuration file at path are stored
+func HistoryDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "history")
+}
+
+// ConfigHistory returns the previous versions of the configuration
+// file at path, sorted from the most recent to the oldest
+func ConfigHistory(path string) ([]*HistoryEntry, error) {
+	dir := HistoryDir(path)
+	prefix := filepath.Base(path) + "."
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*HistoryEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entries := []*HistoryEntry{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), prefix) {
+			continue
+		}
+
+		t, err := time.ParseInLocation(historyTimeFormat, strings.TrimPrefix(file.Name(), prefix), time.Local)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, &HistoryEntry{Path: filepath.Join(dir, file.Name()), Time: t})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// archiveConfig stores a copy of the configuration file at path in
+// the history directory if its contents differ from the ones about to
+// be written, and removes the oldest entries that exceed the history
+// size
+func archiveConfig(path string, newBytes []byte) error {
+	oldBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if bytes.Equal(oldBytes, newBytes) {
+		return nil
+	}
+
+	dir := HistoryDir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	historyPath := filepath.Join(dir, filepath.Base(path)+"."+time.Now().Format(historyTimeFormat))
+	if err := writeFileAtomic(historyPath, oldBytes, 0600); err != nil {
+		return err
+	}
+
+	entries, err := ConfigHistory(path)
+	if err != nil {
+		return err
+	}
+
+	for i := CONFIG_HISTORY_SIZE; i < len(entries); i++ {
+		if err := os.Remove(entries[i].Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory
+// as path and renames it to path once its contents are synced to disk,
+// so path either contains the old or the new contents but is never
+// truncated. If path exists, its permissions and ownership are kept
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	var uid, gid = -1, -1
+	if stat, err := os.Stat(path); err == nil {
+		perm = stat.Mode().Perm()
+		if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(sys.Uid), int(sys.Gid)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+
+	if uid != -1 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// the directory is synced as well so the rename is persisted
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// RevertConfig reads the nth previous version of the configuration
+// file at path, starting from 1 for the most recent one
+func RevertConfig(path string, n int) (*Config, error) {
+	entries, err := ConfigHistory(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 1 || n > len(entries) {
+		return nil, fmt.Errorf("there is no configuration history entry number %d, %d entries available", n, len(entries))
+	}
+
+	config, err := ReadConfig(entries[n-1].Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration history entry %q: %w", entries[n-1].Path, err)
+	}
+
+	config.Path = path
+
+	// history entries that don't set secrets_file would default it to
+	// the history directory, so the live secrets file is used instead
+	if config.node == nil || len(config.node.Content) == 0 || mappingValue(config.node.Content[0], "secrets_file") == nil {
+		config.SecretsFile = NewString(defaultSecretsPath(path))
+		if err := config.LoadSecrets(*config.SecretsFile); err != nil {
+			return nil, fmt.Errorf("cannot read secrets file: %w", err)
+		}
+	}
+
+	return config, nil
+}
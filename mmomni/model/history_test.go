@@ -0,0 +1,132 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mmomni.yml")
+
+	t.Run("writing a new config should not create history", func(t *testing.T) {
+		config := &Config{Path: path}
+		config.SetDefaults()
+		config.FQDN = NewString("first.example.com")
+		require.NoError(t, config.WriteToDisk())
+
+		entries, err := ConfigHistory(path)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0640), stat.Mode().Perm())
+	})
+
+	t.Run("writing the same contents should not create history", func(t *testing.T) {
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.NoError(t, config.WriteToDisk())
+
+		entries, err := ConfigHistory(path)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("changes should store the previous version", func(t *testing.T) {
+		require.NoError(t, os.Chmod(path, 0600))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		config.FQDN = NewString("second.example.com")
+		require.NoError(t, config.WriteToDisk())
+
+		entries, err := ConfigHistory(path)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		previous, err := ReadConfig(entries[0].Path)
+		require.NoError(t, err)
+		require.Equal(t, "first.example.com", *previous.FQDN)
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), stat.Mode().Perm(), "existing permissions should be kept")
+	})
+
+	t.Run("reverting should restore a previous version and archive the current one", func(t *testing.T) {
+		config, err := RevertConfig(path, 1)
+		require.NoError(t, err)
+		require.Equal(t, path, config.Path)
+		require.Equal(t, "first.example.com", *config.FQDN)
+		require.NoError(t, config.Save())
+
+		current, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "first.example.com", *current.FQDN)
+
+		entries, err := ConfigHistory(path)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		previous, err := ReadConfig(entries[0].Path)
+		require.NoError(t, err)
+		require.Equal(t, "second.example.com", *previous.FQDN)
+	})
+
+	t.Run("reverting to an entry without secrets_file should keep the live secrets", func(t *testing.T) {
+		legacyDir, err := ioutil.TempDir("", "mmomni_")
+		require.NoError(t, err)
+		defer os.RemoveAll(legacyDir)
+
+		legacyPath := filepath.Join(legacyDir, "mmomni.yml")
+		require.NoError(t, os.MkdirAll(HistoryDir(legacyPath), 0700))
+		require.NoError(t, ioutil.WriteFile(legacyPath, []byte("fqdn: new.example.com\n"), 0640))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, SECRETS_FILENAME), []byte("db_password: live\n"), 0600))
+		entryPath := filepath.Join(HistoryDir(legacyPath), "mmomni.yml.20200101_000000.000000000")
+		require.NoError(t, ioutil.WriteFile(entryPath, []byte("fqdn: old.example.com\n"), 0600))
+
+		config, err := RevertConfig(legacyPath, 1)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(legacyDir, SECRETS_FILENAME), *config.SecretsFile)
+		require.Equal(t, "live", *config.DBPassword)
+		require.NoError(t, config.Save())
+
+		_, err = os.Stat(filepath.Join(HistoryDir(legacyPath), SECRETS_FILENAME))
+		require.True(t, os.IsNotExist(err), "secrets should not be written to the history directory")
+	})
+
+	t.Run("reverting to an unexisting entry should fail", func(t *testing.T) {
+		_, err := RevertConfig(path, 0)
+		require.Error(t, err)
+		_, err = RevertConfig(path, 3)
+		require.Error(t, err)
+	})
+
+	t.Run("history should be rotated", func(t *testing.T) {
+		for i := 0; i < CONFIG_HISTORY_SIZE+5; i++ {
+			config, err := ReadConfig(path)
+			require.NoError(t, err)
+			config.ClientMaxBodySize = NewString(string(rune('a'+i)) + "M")
+			require.NoError(t, config.WriteToDisk())
+		}
+
+		entries, err := ConfigHistory(path)
+		require.NoError(t, err)
+		require.Len(t, entries, CONFIG_HISTORY_SIZE)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		for _, file := range files {
+			require.Contains(t, []string{"mmomni.yml", SECRETS_FILENAME, "history"}, file.Name(), "no temporary files should be left behind")
+		}
+	})
+}
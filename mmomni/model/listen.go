@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ListenDirectives contains the rendered NGINX listen directives of
+// each kind of server block
+type ListenDirectives struct {
+	// HTTPDefault is used by the default server of the http port
+	HTTPDefault []string
+	// HTTP is used by the aliases server without https
+	HTTP []string
+	// HTTPS is used by the https server blocks
+	HTTPS []string
+}
+
+// isValidPort checks that a number is a TCP port
+func isValidPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// splitListen returns the host and port of a listen address
+func splitListen(address string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || !isValidPort(port) {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	if host != "" && net.ParseIP(host) == nil {
+		return "", 0, fmt.Errorf("host %q must be an IP address", host)
+	}
+
+	return host, port, nil
+}
+
+// isValidListen checks the ports and bind addresses of NGINX and
+// Mattermost
+func (c *Config) isValidListen() error {
+	if !isValidPort(*c.HTTPPort) {
+		return fmt.Errorf("http_port must be a valid TCP port")
+	}
+
+	if !isValidPort(*c.HTTPSPort) {
+		return fmt.Errorf("https_port must be a valid TCP port")
+	}
+
+	if *c.HTTPS && *c.HTTPPort == *c.HTTPSPort {
+		return fmt.Errorf("http_port and https_port must be different")
+	}
+
+	for _, address := range c.BindAddresses {
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf("bind_addresses must contain IP addresses, found %q", address)
+		}
+	}
+
+	_, port, err := splitListen(*c.MattermostListen)
+	if err != nil {
+		return fmt.Errorf("invalid mattermost_listen %q: %w", *c.MattermostListen, err)
+	}
+
+	if port == *c.HTTPPort || (*c.HTTPS && port == *c.HTTPSPort) {
+		return fmt.Errorf("the mattermost_listen port %d is already used by NGINX", port)
+	}
+
+	return nil
+}
+
+// MattermostUpstream returns the address NGINX uses to connect to
+// Mattermost. If Mattermost listens on all the interfaces, the
+// loopback address is used
+func (c *Config) MattermostUpstream() string {
+	host, port, _ := splitListen(*c.MattermostListen)
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// ListenPorts returns the TCP ports that NGINX and Mattermost listen on
+func (c *Config) ListenPorts() []int {
+	ports := []int{*c.HTTPPort}
+	if *c.HTTPS {
+		ports = append(ports, *c.HTTPSPort)
+	}
+
+	_, port, _ := splitListen(*c.MattermostListen)
+	return append(ports, port)
+}
+
+// nginxListen returns the listen directives of a port on every bind
+// address, or on all the IPv4 and IPv6 addresses if none is set
+func (c *Config) nginxListen(port int, options ...string) []string {
+	if c.ProxyProtocol() {
+		options = append(options, "proxy_protocol")
+	}
+
+	addresses := []string{}
+	if len(c.BindAddresses) == 0 {
+		addresses = append(addresses, strconv.Itoa(port), "[::]:"+strconv.Itoa(port))
+	}
+	for _, address := range c.BindAddresses {
+		addresses = append(addresses, net.JoinHostPort(address, strconv.Itoa(port)))
+	}
+
+	directives := []string{}
+	for _, address := range addresses {
+		directives = append(directives, "listen "+strings.Join(append([]string{address}, options...), " ")+";")
+	}
+	return directives
+}
+
+// NginxListenDirectives returns the listen directives of the NGINX
+// server blocks
+func (c *Config) NginxListenDirectives() *ListenDirectives {
+	return &ListenDirectives{
+		HTTPDefault: c.nginxListen(*c.HTTPPort, "default_server"),
+		HTTP:        c.nginxListen(*c.HTTPPort),
+		HTTPS:       c.nginxListen(*c.HTTPSPort, "ssl", "http2"),
+	}
+}
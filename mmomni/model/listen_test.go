@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com")})
+		require.NoError(t, c.isValidListen())
+		require.Equal(t, "127.0.0.1:8065", c.MattermostUpstream())
+		require.Equal(t, []int{80, 8065}, c.ListenPorts())
+		require.Equal(t, "http://mattermost.example.com", c.SiteURL())
+
+		listen := c.NginxListenDirectives()
+		require.Equal(t, []string{"listen 80 default_server;", "listen [::]:80 default_server;"}, listen.HTTPDefault)
+		require.Equal(t, []string{"listen 80;", "listen [::]:80;"}, listen.HTTP)
+		require.Equal(t, []string{"listen 443 ssl http2;", "listen [::]:443 ssl http2;"}, listen.HTTPS)
+	})
+
+	t.Run("Custom ports and bind addresses", func(t *testing.T) {
+		c := withDefaults(&Config{
+			FQDN:             NewString("mattermost.example.com"),
+			HTTPS:            NewBool(true),
+			TLS:              &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED)},
+			HTTPPort:         NewInt(8080),
+			HTTPSPort:        NewInt(8443),
+			BindAddresses:    []string{"192.0.2.10", "2001:db8::10"},
+			MattermostListen: NewString("[::]:9065"),
+		})
+		require.NoError(t, c.isValidListen())
+		require.Equal(t, "[::1]:9065", c.MattermostUpstream())
+		require.Equal(t, []int{8080, 8443, 9065}, c.ListenPorts())
+		require.Equal(t, "https://mattermost.example.com:8443", c.SiteURL())
+
+		listen := c.NginxListenDirectives()
+		require.Equal(t, []string{"listen 192.0.2.10:8080 default_server;", "listen [2001:db8::10]:8080 default_server;"}, listen.HTTPDefault)
+		require.Equal(t, []string{"listen 192.0.2.10:8443 ssl http2;", "listen [2001:db8::10]:8443 ssl http2;"}, listen.HTTPS)
+	})
+
+	t.Run("PROXY protocol", func(t *testing.T) {
+		c := withDefaults(&Config{
+			FQDN:         NewString("mattermost.example.com"),
+			ProxyMode:    NewString(PROXY_MODE_BEHIND_LB),
+			LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10"}, ProxyProtocol: NewBool(true)},
+			HTTPPort:     NewInt(8080),
+		})
+		require.Equal(t, []string{"listen 8080 default_server proxy_protocol;", "listen [::]:8080 default_server proxy_protocol;"}, c.NginxListenDirectives().HTTPDefault)
+		require.Equal(t, "https://mattermost.example.com", c.SiteURL())
+	})
+
+	testCases := []struct {
+		Name   string
+		Update func(*Config)
+	}{
+		{
+			Name:   "Invalid http_port",
+			Update: func(c *Config) { c.HTTPPort = NewInt(0) },
+		},
+		{
+			Name:   "Invalid https_port",
+			Update: func(c *Config) { c.HTTPSPort = NewInt(70000) },
+		},
+		{
+			Name: "Same http and https ports",
+			Update: func(c *Config) {
+				c.HTTPS = NewBool(true)
+				c.HTTPSPort = NewInt(80)
+			},
+		},
+		{
+			Name:   "Invalid bind address",
+			Update: func(c *Config) { c.BindAddresses = []string{"eth0"} },
+		},
+		{
+			Name:   "mattermost_listen without port",
+			Update: func(c *Config) { c.MattermostListen = NewString("127.0.0.1") },
+		},
+		{
+			Name:   "mattermost_listen with a hostname",
+			Update: func(c *Config) { c.MattermostListen = NewString("localhost:8065") },
+		},
+		{
+			Name:   "mattermost_listen on the NGINX port",
+			Update: func(c *Config) { c.MattermostListen = NewString("127.0.0.1:80") },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := &Config{FQDN: NewString("mattermost.example.com")}
+			tc.Update(c)
+			require.Error(t, withDefaults(c).isValidListen())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_VERSION is the version of the configuration format that this
+// mmomni binary writes. Every time the format changes in a way that
+// needs old configuration files to be updated, a migration has to be
+// added and this number increased
+const CONFIG_VERSION = 2
+
+// migration upgrades the configuration document to its version. It
+// receives the root mapping of the document and the secrets moved out
+// of it, and returns a human readable description of each change
+// applied
+type migration struct {
+	version     int
+	description string
+	migrate     func(root *yaml.Node, secrets *Secrets) ([]string, error)
+}
+
+// configMigrations contains the list of migrations, sorted by version
+var configMigrations = []migration{
+	{
+		version:     1,
+		description: "add config_version to the configuration",
+		migrate: func(_ *yaml.Node, _ *Secrets) ([]string, error) {
+			return nil, nil
+		},
+	},
+	{
+		version:     2,
+		description: "move db_password to the secrets file",
+		migrate: func(root *yaml.Node, secrets *Secrets) ([]string, error) {
+			for i := 0; i+1 < len(root.Content); i += 2 {
+				if root.Content[i].Value != "db_password" {
+					continue
+				}
+
+				password := root.Content[i+1].Value
+				secrets.DBPassword = &password
+				root.Content = append(root.Content[:i], root.Content[i+2:]...)
+				return []string{"removed db_password from the configuration file"}, nil
+			}
+			return nil, nil
+		},
+	},
+}
+
+// migrateConfig upgrades the configuration document step by step up
+// to the version of the last migration, returning the list of changes
+// applied. Secrets removed from the document are stored in secrets
+func migrateConfig(doc *yaml.Node, migrations []migration, secrets *Secrets) ([]string, error) {
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("configuration must be a YAML mapping")
+	}
+
+	version, err := configVersion(root)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+	if version > latest {
+		return nil, fmt.Errorf("configuration version %d is newer than the latest version supported by this Omnibus release (%d), please upgrade Omnibus", version, latest)
+	}
+
+	changes := []string{}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		migrationChanges, err := m.migrate(root, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("error migrating configuration to version %d: %w", m.version, err)
+		}
+
+		changes = append(changes, fmt.Sprintf("migrated configuration to version %d: %s", m.version, m.description))
+		for _, change := range migrationChanges {
+			changes = append(changes, fmt.Sprintf("  - %s", change))
+		}
+
+		setVersion(root, m.version)
+	}
+
+	return changes, nil
+}
+
+func configVersion(root *yaml.Node) (int, error) {
+	node := mappingValue(root, "config_version")
+	if node == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || node.Kind != yaml.ScalarNode || version < 0 {
+		return 0, fmt.Errorf("invalid config_version %q", node.Value)
+	}
+
+	return version, nil
+}
+
+// setVersion updates the config_version key, adding it at the top of
+// the document if it doesn't exist yet
+func setVersion(root *yaml.Node, version int) {
+	if node := mappingValue(root, "config_version"); node != nil {
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!int"
+		node.Value = strconv.Itoa(version)
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "config_version"}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func parseDocument(t *testing.T, contents string) *yaml.Node {
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(contents), &doc))
+	return &doc
+}
+
+func TestMigrateConfig(t *testing.T) {
+	testMigrations := []migration{
+		{
+			version:     1,
+			description: "rename hostname",
+			migrate: func(root *yaml.Node, _ *Secrets) ([]string, error) {
+				for i := 0; i+1 < len(root.Content); i += 2 {
+					if root.Content[i].Value == "hostname" {
+						root.Content[i].Value = "fqdn"
+						return []string{`renamed "hostname" to "fqdn"`}, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{
+			version:     2,
+			description: "stringify body size",
+			migrate: func(root *yaml.Node, _ *Secrets) ([]string, error) {
+				value := mappingValue(root, "client_max_body_size")
+				if value == nil {
+					return nil, nil
+				}
+				value.Tag = "!!str"
+				value.Value += "M"
+				return []string{`converted "client_max_body_size" to !!str`}, nil
+			},
+		},
+	}
+
+	t.Run("CONFIG_VERSION should match the last migration", func(t *testing.T) {
+		require.Equal(t, CONFIG_VERSION, configMigrations[len(configMigrations)-1].version)
+	})
+
+	t.Run("db_password should be moved out of the configuration", func(t *testing.T) {
+		doc := parseDocument(t, "config_version: 1\ndb_user: mmuser\ndb_password: legacy-password\n")
+		secrets := &Secrets{}
+
+		changes, err := migrateConfig(doc, configMigrations, secrets)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"migrated configuration to version 2: move db_password to the secrets file",
+			"  - removed db_password from the configuration file",
+		}, changes)
+		require.Equal(t, "legacy-password", *secrets.DBPassword)
+		require.Nil(t, mappingValue(doc.Content[0], "db_password"))
+		require.Equal(t, "2", mappingValue(doc.Content[0], "config_version").Value)
+	})
+
+	t.Run("a config without version should run all migrations", func(t *testing.T) {
+		doc := parseDocument(t, "hostname: example.com\nclient_max_body_size: 50\n")
+
+		changes, err := migrateConfig(doc, testMigrations, &Secrets{})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"migrated configuration to version 1: rename hostname",
+			`  - renamed "hostname" to "fqdn"`,
+			"migrated configuration to version 2: stringify body size",
+			`  - converted "client_max_body_size" to !!str`,
+		}, changes)
+
+		var cfg Config
+		require.NoError(t, doc.Decode(&cfg))
+		require.Equal(t, 2, *cfg.ConfigVersion)
+		require.Equal(t, "example.com", *cfg.FQDN)
+		require.Equal(t, "50M", *cfg.ClientMaxBodySize)
+		require.Equal(t, "config_version", doc.Content[0].Content[0].Value)
+	})
+
+	t.Run("only the pending migrations should run", func(t *testing.T) {
+		doc := parseDocument(t, "config_version: 1\nhostname: example.com\nclient_max_body_size: 50\n")
+
+		changes, err := migrateConfig(doc, testMigrations, &Secrets{})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		require.NotNil(t, mappingValue(doc.Content[0], "hostname"))
+		require.Equal(t, "2", mappingValue(doc.Content[0], "config_version").Value)
+	})
+
+	t.Run("an up to date config should not change", func(t *testing.T) {
+		doc := parseDocument(t, "config_version: 2\nhostname: example.com\n")
+
+		changes, err := migrateConfig(doc, testMigrations, &Secrets{})
+		require.NoError(t, err)
+		require.Empty(t, changes)
+		require.NotNil(t, mappingValue(doc.Content[0], "hostname"))
+	})
+
+	t.Run("a config from a newer version should be rejected", func(t *testing.T) {
+		doc := parseDocument(t, "config_version: 3\n")
+
+		_, err := migrateConfig(doc, testMigrations, &Secrets{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "newer than the latest version supported")
+	})
+
+	t.Run("an invalid version should be rejected", func(t *testing.T) {
+		doc := parseDocument(t, "config_version: two\n")
+
+		_, err := migrateConfig(doc, testMigrations, &Secrets{})
+		require.Error(t, err)
+	})
+
+	t.Run("a failing migration should stop the process", func(t *testing.T) {
+		doc := parseDocument(t, "fqdn: example.com\n")
+		failing := []migration{{
+			version:     1,
+			description: "fail",
+			migrate: func(_ *yaml.Node, _ *Secrets) ([]string, error) {
+				return nil, fmt.Errorf("boom")
+			},
+		}}
+
+		_, err := migrateConfig(doc, failing, &Secrets{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "boom")
+	})
+}
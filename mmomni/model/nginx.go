@@ -0,0 +1,161 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	nginxTimeRegexp = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d)?$`)
+	nginxSizeRegexp = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+)
+
+// NginxConfig customizes the stock NGINX template. Server directives
+// and locations are added to the Mattermost server block, and the
+// *.conf files of the include directory are included in it
+type NginxConfig struct {
+	ServerDirectives     []string               `yaml:"server_directives,omitempty"`
+	Locations            []*NginxLocationConfig `yaml:"locations,omitempty"`
+	IncludeDir           *string                `yaml:"include_dir"`
+	ProxyConnectTimeout  *string                `yaml:"proxy_connect_timeout"`
+	ProxySendTimeout     *string                `yaml:"proxy_send_timeout"`
+	ProxyReadTimeout     *string                `yaml:"proxy_read_timeout"`
+	WebsocketReadTimeout *string                `yaml:"websocket_read_timeout"`
+	CacheMaxSize         *string                `yaml:"cache_max_size"`
+	RateLimits           *RateLimitsConfig      `yaml:"rate_limits,omitempty"`
+}
+
+// NginxLocationConfig is an additional location block. The path can
+// include a modifier, e.g. "= /robots.txt" or "~ \.pdf$"
+type NginxLocationConfig struct {
+	Path       *string  `yaml:"path"`
+	Directives []string `yaml:"directives"`
+}
+
+func (n *NginxConfig) SetDefaults() {
+	if n.IncludeDir == nil {
+		n.IncludeDir = NewString("")
+	}
+
+	if n.ProxyConnectTimeout == nil {
+		n.ProxyConnectTimeout = NewString("90s")
+	}
+
+	if n.ProxySendTimeout == nil {
+		n.ProxySendTimeout = NewString("300s")
+	}
+
+	if n.ProxyReadTimeout == nil {
+		n.ProxyReadTimeout = NewString("600s")
+	}
+
+	if n.WebsocketReadTimeout == nil {
+		n.WebsocketReadTimeout = NewString("90s")
+	}
+
+	if n.CacheMaxSize == nil {
+		n.CacheMaxSize = NewString("3g")
+	}
+
+	if n.RateLimits != nil {
+		n.RateLimits.SetDefaults()
+	}
+}
+
+// isValidDirective performs basic checks on a raw NGINX directive, so
+// mistakes are reported before the configuration is rendered. NGINX
+// itself validates the result
+func isValidDirective(directive string) error {
+	directive = strings.TrimSpace(directive)
+	if directive == "" {
+		return fmt.Errorf("directives cannot be empty")
+	}
+
+	if !strings.HasSuffix(directive, ";") && !strings.HasSuffix(directive, "}") {
+		return fmt.Errorf("directive %q must end with \";\" or \"}\"", directive)
+	}
+
+	depth := 0
+	for _, r := range directive {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("directive %q has unbalanced braces", directive)
+	}
+
+	return nil
+}
+
+func (n *NginxConfig) IsValid() error {
+	for _, directive := range n.ServerDirectives {
+		if err := isValidDirective(directive); err != nil {
+			return fmt.Errorf("invalid server_directives: %w", err)
+		}
+	}
+
+	for _, location := range n.Locations {
+		if location == nil || location.Path == nil || strings.TrimSpace(*location.Path) == "" {
+			return fmt.Errorf("locations must have a path")
+		}
+
+		if strings.ContainsAny(*location.Path, "{};\n") {
+			return fmt.Errorf("invalid location path %q", *location.Path)
+		}
+
+		for _, directive := range location.Directives {
+			if err := isValidDirective(directive); err != nil {
+				return fmt.Errorf("invalid directives for location %q: %w", *location.Path, err)
+			}
+		}
+	}
+
+	if *n.IncludeDir != "" && !filepath.IsAbs(*n.IncludeDir) {
+		return fmt.Errorf("include_dir must be an absolute path")
+	}
+
+	timeouts := map[string]string{
+		"proxy_connect_timeout":  *n.ProxyConnectTimeout,
+		"proxy_send_timeout":     *n.ProxySendTimeout,
+		"proxy_read_timeout":     *n.ProxyReadTimeout,
+		"websocket_read_timeout": *n.WebsocketReadTimeout,
+	}
+	for name, value := range timeouts {
+		if !nginxTimeRegexp.MatchString(value) {
+			return fmt.Errorf("%s must be an NGINX time, e.g. 90s or 5m", name)
+		}
+	}
+
+	if !nginxSizeRegexp.MatchString(*n.CacheMaxSize) {
+		return fmt.Errorf("cache_max_size must be an NGINX size, e.g. 500m or 3g")
+	}
+
+	if n.RateLimits != nil {
+		if err := n.RateLimits.IsValid(); err != nil {
+			return fmt.Errorf("invalid rate_limits: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NginxSettings returns the nginx section, or its defaults if the
+// section is not present
+func (c *Config) NginxSettings() *NginxConfig {
+	if c.Nginx != nil {
+		return c.Nginx
+	}
+
+	n := &NginxConfig{}
+	n.SetDefaults()
+	return n
+}
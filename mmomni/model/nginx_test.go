@@ -0,0 +1,113 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNginxConfig(t *testing.T) {
+	defaults := (&Config{}).NginxSettings()
+	require.Equal(t, "600s", *defaults.ProxyReadTimeout)
+	require.Equal(t, "90s", *defaults.WebsocketReadTimeout)
+	require.Equal(t, "3g", *defaults.CacheMaxSize)
+	require.Equal(t, "", *defaults.IncludeDir)
+
+	newNginx := func(update func(*NginxConfig)) *NginxConfig {
+		n := &NginxConfig{}
+		update(n)
+		n.SetDefaults()
+		return n
+	}
+
+	testCases := []struct {
+		Name        string
+		Nginx       *NginxConfig
+		ExpectError bool
+	}{
+		{
+			Name:  "Defaults are valid",
+			Nginx: newNginx(func(n *NginxConfig) {}),
+		},
+		{
+			Name: "Valid customizations",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.ServerDirectives = []string{"gzip on;", "error_page 502 /502.html;"}
+				n.Locations = []*NginxLocationConfig{
+					{Path: NewString("= /robots.txt"), Directives: []string{"return 200 \"User-agent: *\\nDisallow: /\\n\";"}},
+					{Path: NewString("/502.html"), Directives: []string{"root /var/www/errors;", "if ($http_x_debug) { return 404; }"}},
+				}
+				n.IncludeDir = NewString("/etc/mattermost/nginx.d")
+				n.ProxyReadTimeout = NewString("15m")
+				n.CacheMaxSize = NewString("500m")
+			}),
+		},
+		{
+			Name: "Directives must be terminated",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.ServerDirectives = []string{"gzip on"}
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Directives can't be empty",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.ServerDirectives = []string{" "}
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Directives can't close the server block",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.ServerDirectives = []string{"} server { listen 8080;"}
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Locations require a path",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.Locations = []*NginxLocationConfig{{Directives: []string{"return 404;"}}}
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Location paths can't contain braces",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.Locations = []*NginxLocationConfig{{Path: NewString("/a {"), Directives: []string{"return 404;"}}}
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Include dir must be absolute",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.IncludeDir = NewString("nginx.d")
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Timeouts must be NGINX times",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.ProxyConnectTimeout = NewString("1 minute")
+			}),
+			ExpectError: true,
+		},
+		{
+			Name: "Cache size must be an NGINX size",
+			Nginx: newNginx(func(n *NginxConfig) {
+				n.CacheMaxSize = NewString("3GB")
+			}),
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Nginx.IsValid()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// PluginConfig describes a plugin that reconfigure installs and
+// enables or disables. Plugins that are installed but not listed are
+// disabled
+type PluginConfig struct {
+	ID      *string `yaml:"id"`
+	Source  *string `yaml:"source,omitempty"`
+	Enabled *bool   `yaml:"enabled,omitempty"`
+}
+
+// IsEnabled returns true if the plugin should be enabled, which is
+// the default when enabled is not set
+func (p *PluginConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// IsURL returns true if the plugin source is an http or https URL
+// instead of a local file
+func (p *PluginConfig) IsURL() bool {
+	return p.Source != nil && IsPluginURL(*p.Source)
+}
+
+// IsPluginURL returns true if source is an http or https URL
+func IsPluginURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (p *PluginConfig) IsValid() error {
+	if p.ID == nil || *p.ID == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+
+	if p.Source != nil && *p.Source != "" && !p.IsURL() && !filepath.IsAbs(*p.Source) {
+		return fmt.Errorf("source of plugin %q must be an http or https URL or an absolute file path", *p.ID)
+	}
+
+	return nil
+}
+
+func (c *Config) isValidPlugins() error {
+	ids := map[string]bool{}
+	hasSource := false
+	for _, plugin := range c.Plugins {
+		if plugin == nil {
+			return fmt.Errorf("plugins cannot contain empty entries")
+		}
+
+		if err := plugin.IsValid(); err != nil {
+			return err
+		}
+
+		if ids[*plugin.ID] {
+			return fmt.Errorf("plugin %q is listed more than once", *plugin.ID)
+		}
+		ids[*plugin.ID] = true
+
+		if plugin.Source != nil && *plugin.Source != "" {
+			hasSource = true
+		}
+	}
+
+	// Mattermost rejects plugin installations, even through local
+	// mode, if uploads are disabled
+	if hasSource && !*c.EnablePluginUploads {
+		return fmt.Errorf("enable_plugin_uploads must be true to install plugins from a source")
+	}
+
+	return nil
+}
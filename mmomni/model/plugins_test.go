@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidPlugins(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		Config      *Config
+		ExpectError bool
+	}{
+		{
+			Name:   "Plugins without source are valid",
+			Config: withDefaults(&Config{EnablePluginUploads: NewBool(true), Plugins: []*PluginConfig{{ID: NewString("playbooks")}, {ID: NewString("jitsi"), Enabled: NewBool(false)}}}),
+		},
+		{
+			Name:   "URL and absolute path sources are valid",
+			Config: withDefaults(&Config{EnablePluginUploads: NewBool(true), Plugins: []*PluginConfig{{ID: NewString("github"), Source: NewString("https://example.com/github.tar.gz")}, {ID: NewString("todo"), Source: NewString("/tmp/todo.tar.gz")}}}),
+		},
+		{
+			Name:        "Empty ids are invalid",
+			Config:      withDefaults(&Config{EnablePluginUploads: NewBool(true), Plugins: []*PluginConfig{{ID: NewString("")}}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Duplicated ids are invalid",
+			Config:      withDefaults(&Config{EnablePluginUploads: NewBool(true), Plugins: []*PluginConfig{{ID: NewString("jitsi")}, {ID: NewString("jitsi")}}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Relative path sources are invalid",
+			Config:      withDefaults(&Config{EnablePluginUploads: NewBool(true), Plugins: []*PluginConfig{{ID: NewString("todo"), Source: NewString("todo.tar.gz")}}}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Sources require plugin uploads",
+			Config:      withDefaults(&Config{EnablePluginUploads: NewBool(false), Plugins: []*PluginConfig{{ID: NewString("todo"), Source: NewString("/tmp/todo.tar.gz")}}}),
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Config.isValidPlugins()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// POSTGRES_AUTO computes a setting from the host resources
+	POSTGRES_AUTO = "auto"
+
+	POSTGRES_DROPIN_FILENAME = "mmomni.conf"
+)
+
+var postgresSizeRegexp = regexp.MustCompile(`^([1-9][0-9]*)(kB|MB|GB|TB)$`)
+
+var postgresSizeUnits = map[string]int{"kB": 1, "MB": 1024, "GB": 1024 * 1024, "TB": 1024 * 1024 * 1024}
+
+// postgresRestartSettings are the drop-in settings that only take
+// effect after restarting PostgreSQL
+var postgresRestartSettings = []string{"max_connections", "shared_buffers"}
+
+// HostResources contains the resources of the host used to compute
+// the auto PostgreSQL settings
+type HostResources struct {
+	MemoryMB int
+	CPUs     int
+}
+
+// PostgresConfig contains the PostgreSQL settings written to a
+// drop-in of the local cluster, and the connection pool settings of
+// Mattermost. Every setting can be set to auto to compute it from the
+// host resources
+type PostgresConfig struct {
+	SharedBuffers      *string `yaml:"shared_buffers"`
+	EffectiveCacheSize *string `yaml:"effective_cache_size"`
+	WorkMem            *string `yaml:"work_mem"`
+	MaxConnections     *string `yaml:"max_connections"`
+	MaxOpenConns       *string `yaml:"max_open_conns"`
+	MaxIdleConns       *string `yaml:"max_idle_conns"`
+}
+
+// PostgresSettings are the computed values of the postgres section
+type PostgresSettings struct {
+	SharedBuffers      string
+	EffectiveCacheSize string
+	WorkMem            string
+	MaxConnections     int
+	MaxOpenConns       int
+	MaxIdleConns       int
+}
+
+func (p *PostgresConfig) SetDefaults() {
+	for _, setting := range []**string{&p.SharedBuffers, &p.EffectiveCacheSize, &p.WorkMem, &p.MaxConnections, &p.MaxOpenConns, &p.MaxIdleConns} {
+		if *setting == nil {
+			*setting = NewString(POSTGRES_AUTO)
+		}
+	}
+}
+
+func (p *PostgresConfig) IsValid() error {
+	sizes := map[string]string{
+		"shared_buffers":       *p.SharedBuffers,
+		"effective_cache_size": *p.EffectiveCacheSize,
+		"work_mem":             *p.WorkMem,
+	}
+	for name, value := range sizes {
+		if value != POSTGRES_AUTO && !postgresSizeRegexp.MatchString(value) {
+			return fmt.Errorf("%s must be %q or a PostgreSQL size, e.g. 512MB or 2GB", name, POSTGRES_AUTO)
+		}
+	}
+
+	counts := map[string]string{
+		"max_connections": *p.MaxConnections,
+		"max_open_conns":  *p.MaxOpenConns,
+		"max_idle_conns":  *p.MaxIdleConns,
+	}
+	for name, value := range counts {
+		if value == POSTGRES_AUTO {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("%s must be %q or a positive number", name, POSTGRES_AUTO)
+		}
+	}
+
+	return nil
+}
+
+// postgresSizeKB returns the size in kB of a PostgreSQL size setting
+func postgresSizeKB(size string) int {
+	match := postgresSizeRegexp.FindStringSubmatch(size)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n * postgresSizeUnits[match[2]]
+}
+
+// autoCount returns the number of a setting, or the computed value if
+// it's auto
+func autoCount(value string, auto int) int {
+	if value == POSTGRES_AUTO {
+		return auto
+	}
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// autoSize returns the size of a setting, or the computed value in MB
+// if it's auto
+func autoSize(value string, autoMB int) string {
+	if value == POSTGRES_AUTO {
+		return strconv.Itoa(autoMB) + "MB"
+	}
+	return value
+}
+
+// Settings computes the auto settings from the host resources. The
+// shared buffers use a quarter of the memory, the effective cache size
+// three quarters, and the work memory splits the rest between the
+// connections. Mattermost uses up to three quarters of the
+// connections, so there are connections left for backups and
+// administration
+func (p *PostgresConfig) Settings(resources *HostResources) (*PostgresSettings, error) {
+	maxConnections := autoCount(*p.MaxConnections, clamp(resources.CPUs*50, 100, 400))
+	sharedBuffers := autoSize(*p.SharedBuffers, clamp(resources.MemoryMB/4, 128, resources.MemoryMB))
+	workMem := autoSize(*p.WorkMem, clamp((resources.MemoryMB-postgresSizeKB(sharedBuffers)/1024)/(maxConnections*3), 4, 1024))
+	maxOpenConns := autoCount(*p.MaxOpenConns, maxConnections*3/4)
+
+	settings := &PostgresSettings{
+		SharedBuffers:      sharedBuffers,
+		EffectiveCacheSize: autoSize(*p.EffectiveCacheSize, clamp(resources.MemoryMB*3/4, 128, resources.MemoryMB)),
+		WorkMem:            workMem,
+		MaxConnections:     maxConnections,
+		MaxOpenConns:       maxOpenConns,
+		MaxIdleConns:       autoCount(*p.MaxIdleConns, clamp(maxOpenConns/5, 1, maxOpenConns)),
+	}
+
+	if settings.MaxOpenConns >= settings.MaxConnections {
+		return nil, fmt.Errorf("max_open_conns (%d) must be lower than max_connections (%d)", settings.MaxOpenConns, settings.MaxConnections)
+	}
+
+	if settings.MaxIdleConns > settings.MaxOpenConns {
+		return nil, fmt.Errorf("max_idle_conns (%d) cannot be greater than max_open_conns (%d)", settings.MaxIdleConns, settings.MaxOpenConns)
+	}
+
+	return settings, nil
+}
+
+// clamp limits a value to a range
+func clamp(value, lower, upper int) int {
+	if value < lower {
+		return lower
+	}
+	if value > upper {
+		return upper
+	}
+	return value
+}
+
+// DropIn renders the PostgreSQL configuration drop-in
+func (s *PostgresSettings) DropIn() string {
+	return strings.Join([]string{
+		"# Generated by mmomni from the postgres section of mmomni.yml,",
+		"# changes are overwritten by mmomni reconfigure",
+		fmt.Sprintf("shared_buffers = '%s'", s.SharedBuffers),
+		fmt.Sprintf("effective_cache_size = '%s'", s.EffectiveCacheSize),
+		fmt.Sprintf("work_mem = '%s'", s.WorkMem),
+		fmt.Sprintf("max_connections = %d", s.MaxConnections),
+		"",
+	}, "\n")
+}
+
+// Env returns the environment variables of the Mattermost connection
+// pool
+func (s *PostgresSettings) Env() []*EnvVariable {
+	return []*EnvVariable{
+		{Name: "MM_SQLSETTINGS_MAXOPENCONNS", Value: quoteEnvValue(strconv.Itoa(s.MaxOpenConns))},
+		{Name: "MM_SQLSETTINGS_MAXIDLECONNS", Value: quoteEnvValue(strconv.Itoa(s.MaxIdleConns))},
+	}
+}
+
+func (p *PostgresConfig) managedSettings() []string {
+	return []string{"SqlSettings.MaxOpenConns", "SqlSettings.MaxIdleConns"}
+}
+
+// parsePostgresConf returns the settings of a PostgreSQL configuration
+// file, without quotes
+func parsePostgresConf(content string) map[string]string {
+	settings := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		settings[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), "'")
+	}
+	return settings
+}
+
+// PostgresDropInChanges compares two versions of the drop-in, and
+// returns the names of the settings that changed, sorted, and whether
+// PostgreSQL needs to be restarted to apply them instead of reloaded
+func PostgresDropInChanges(previous, next string) ([]string, bool) {
+	previousSettings := parsePostgresConf(previous)
+	nextSettings := parsePostgresConf(next)
+
+	names := map[string]bool{}
+	for name := range previousSettings {
+		names[name] = true
+	}
+	for name := range nextSettings {
+		names[name] = true
+	}
+
+	changes := []string{}
+	restart := false
+	for name := range names {
+		if previousSettings[name] == nextSettings[name] {
+			continue
+		}
+		changes = append(changes, name)
+		for _, restartSetting := range postgresRestartSettings {
+			restart = restart || name == restartSetting
+		}
+	}
+	sort.Strings(changes)
+
+	return changes, restart
+}
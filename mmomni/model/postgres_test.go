@@ -0,0 +1,108 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresSettings(t *testing.T) {
+	newPostgres := func(update func(*PostgresConfig)) *PostgresConfig {
+		p := &PostgresConfig{}
+		update(p)
+		p.SetDefaults()
+		return p
+	}
+
+	t.Run("Auto settings", func(t *testing.T) {
+		p := newPostgres(func(p *PostgresConfig) {})
+		require.NoError(t, p.IsValid())
+
+		settings, err := p.Settings(&HostResources{MemoryMB: 8192, CPUs: 4})
+		require.NoError(t, err)
+		require.Equal(t, &PostgresSettings{
+			SharedBuffers:      "2048MB",
+			EffectiveCacheSize: "6144MB",
+			WorkMem:            "10MB",
+			MaxConnections:     200,
+			MaxOpenConns:       150,
+			MaxIdleConns:       30,
+		}, settings)
+
+		small, err := p.Settings(&HostResources{MemoryMB: 256, CPUs: 1})
+		require.NoError(t, err)
+		require.Equal(t, "128MB", small.SharedBuffers)
+		require.Equal(t, "4MB", small.WorkMem)
+		require.Equal(t, 100, small.MaxConnections)
+	})
+
+	t.Run("Explicit settings", func(t *testing.T) {
+		p := newPostgres(func(p *PostgresConfig) {
+			p.SharedBuffers = NewString("4GB")
+			p.MaxConnections = NewString("300")
+			p.MaxIdleConns = NewString("50")
+		})
+		require.NoError(t, p.IsValid())
+
+		settings, err := p.Settings(&HostResources{MemoryMB: 16384, CPUs: 8})
+		require.NoError(t, err)
+		require.Equal(t, "4GB", settings.SharedBuffers)
+		require.Equal(t, "13MB", settings.WorkMem)
+		require.Equal(t, 300, settings.MaxConnections)
+		require.Equal(t, 225, settings.MaxOpenConns)
+		require.Equal(t, 50, settings.MaxIdleConns)
+
+		require.Equal(t, []*EnvVariable{
+			{Name: "MM_SQLSETTINGS_MAXOPENCONNS", Value: `"225"`},
+			{Name: "MM_SQLSETTINGS_MAXIDLECONNS", Value: `"50"`},
+		}, settings.Env())
+		require.Contains(t, settings.DropIn(), "shared_buffers = '4GB'\n")
+		require.Contains(t, settings.DropIn(), "max_connections = 300\n")
+	})
+
+	t.Run("Inconsistent connections", func(t *testing.T) {
+		_, err := newPostgres(func(p *PostgresConfig) {
+			p.MaxConnections = NewString("100")
+			p.MaxOpenConns = NewString("100")
+		}).Settings(&HostResources{MemoryMB: 4096, CPUs: 2})
+		require.Error(t, err)
+
+		_, err = newPostgres(func(p *PostgresConfig) {
+			p.MaxOpenConns = NewString("20")
+			p.MaxIdleConns = NewString("30")
+		}).Settings(&HostResources{MemoryMB: 4096, CPUs: 2})
+		require.Error(t, err)
+	})
+
+	for _, update := range []func(*PostgresConfig){
+		func(p *PostgresConfig) { p.SharedBuffers = NewString("2G") },
+		func(p *PostgresConfig) { p.WorkMem = NewString("0MB") },
+		func(p *PostgresConfig) { p.MaxConnections = NewString("many") },
+		func(p *PostgresConfig) { p.MaxIdleConns = NewString("-1") },
+	} {
+		require.Error(t, newPostgres(update).IsValid())
+	}
+}
+
+func TestPostgresDropInChanges(t *testing.T) {
+	settings := &PostgresSettings{SharedBuffers: "1024MB", EffectiveCacheSize: "3072MB", WorkMem: "8MB", MaxConnections: 200}
+	current := settings.DropIn()
+
+	changes, restart := PostgresDropInChanges(current, current)
+	require.Empty(t, changes)
+	require.False(t, restart)
+
+	settings.WorkMem = "16MB"
+	changes, restart = PostgresDropInChanges(current, settings.DropIn())
+	require.Equal(t, []string{"work_mem"}, changes)
+	require.False(t, restart)
+
+	settings.MaxConnections = 300
+	changes, restart = PostgresDropInChanges(current, settings.DropIn())
+	require.Equal(t, []string{"max_connections", "work_mem"}, changes)
+	require.True(t, restart)
+
+	changes, restart = PostgresDropInChanges(current, "")
+	require.Len(t, changes, 4)
+	require.True(t, restart)
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	PROXY_MODE_DIRECT    = "direct"
+	PROXY_MODE_BEHIND_LB = "behind_lb"
+)
+
+// LoadBalancerConfig contains the settings of the behind_lb proxy
+// mode. The load balancer terminates TLS, so the scheme of the SiteURL
+// doesn't depend on the https setting, and the client addresses are
+// taken from the forwarded headers, or from the PROXY protocol if
+// enabled, of the requests sent by the load balancer addresses
+type LoadBalancerConfig struct {
+	Addresses     []string `yaml:"addresses"`
+	SiteURLScheme *string  `yaml:"site_url_scheme"`
+	ProxyProtocol *bool    `yaml:"proxy_protocol"`
+}
+
+func (l *LoadBalancerConfig) SetDefaults() {
+	if l.SiteURLScheme == nil {
+		l.SiteURLScheme = NewString("https")
+	}
+
+	if l.ProxyProtocol == nil {
+		l.ProxyProtocol = NewBool(false)
+	}
+}
+
+func (l *LoadBalancerConfig) IsValid() error {
+	if len(l.Addresses) == 0 {
+		return fmt.Errorf("addresses cannot be empty")
+	}
+
+	for _, address := range l.Addresses {
+		if !isValidAddress(address) {
+			return fmt.Errorf("addresses must contain IP addresses or CIDR ranges, found %q", address)
+		}
+	}
+
+	if *l.SiteURLScheme != "http" && *l.SiteURLScheme != "https" {
+		return fmt.Errorf("site_url_scheme must be either \"http\" or \"https\"")
+	}
+
+	return nil
+}
+
+// BehindLB returns true if Omnibus runs behind an external load
+// balancer
+func (c *Config) BehindLB() bool {
+	return *c.ProxyMode == PROXY_MODE_BEHIND_LB
+}
+
+// isValidProxyMode checks the proxy_mode and the load_balancer section
+func (c *Config) isValidProxyMode() error {
+	switch *c.ProxyMode {
+	case PROXY_MODE_DIRECT:
+		if c.LoadBalancer != nil {
+			return fmt.Errorf("load_balancer can only be set with the %q proxy_mode", PROXY_MODE_BEHIND_LB)
+		}
+		return nil
+	case PROXY_MODE_BEHIND_LB:
+	default:
+		return fmt.Errorf("proxy_mode must be either %q or %q", PROXY_MODE_DIRECT, PROXY_MODE_BEHIND_LB)
+	}
+
+	if c.LoadBalancer == nil {
+		return fmt.Errorf("load_balancer must be set with the %q proxy_mode", PROXY_MODE_BEHIND_LB)
+	}
+
+	if err := c.LoadBalancer.IsValid(); err != nil {
+		return fmt.Errorf("invalid load_balancer configuration: %w", err)
+	}
+
+	if *c.HTTPS && c.TLSMode() == TLS_MODE_LETSENCRYPT {
+		return fmt.Errorf("the %q tls mode can't be used with the %q proxy_mode, as the load balancer terminates TLS. Disable https or use the %q or %q tls modes to encrypt the traffic from the load balancer", TLS_MODE_LETSENCRYPT, PROXY_MODE_BEHIND_LB, TLS_MODE_CUSTOM, TLS_MODE_SELFSIGNED)
+	}
+
+	return nil
+}
+
+// SiteURL returns the URL that Mattermost is served from, including
+// the subpath. Behind a load balancer, the local ports are not the
+// ones clients connect to
+func (c *Config) SiteURL() string {
+	if *c.FQDN == "" {
+		return ""
+	}
+	return c.SiteURLBase() + *c.Subpath
+}
+
+// SiteURLBase returns the scheme and host of the SiteURL, without the
+// subpath
+func (c *Config) SiteURLBase() string {
+	if *c.FQDN == "" {
+		return ""
+	}
+
+	if c.BehindLB() {
+		return *c.LoadBalancer.SiteURLScheme + "://" + *c.FQDN
+	}
+
+	if *c.HTTPS {
+		if *c.HTTPSPort != 443 {
+			return "https://" + net.JoinHostPort(*c.FQDN, strconv.Itoa(*c.HTTPSPort))
+		}
+		return "https://" + *c.FQDN
+	}
+
+	if *c.HTTPPort != 80 {
+		return "http://" + net.JoinHostPort(*c.FQDN, strconv.Itoa(*c.HTTPPort))
+	}
+	return "http://" + *c.FQDN
+}
+
+// NginxForwardedProto returns the value of the X-Forwarded-Proto header
+// sent to Mattermost. Behind a load balancer it's the SiteURL scheme,
+// as the scheme of the local connection is not the one of the clients
+func (c *Config) NginxForwardedProto() string {
+	if c.BehindLB() {
+		return *c.LoadBalancer.SiteURLScheme
+	}
+	return "$scheme"
+}
+
+// ProxyProtocol returns true if NGINX expects the PROXY protocol
+func (c *Config) ProxyProtocol() bool {
+	return c.BehindLB() && *c.LoadBalancer.ProxyProtocol
+}
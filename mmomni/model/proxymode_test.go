@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyMode(t *testing.T) {
+	t.Run("Direct mode", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true)})
+		require.NoError(t, c.isValidProxyMode())
+		require.False(t, c.BehindLB())
+		require.Equal(t, "https://mattermost.example.com", c.SiteURL())
+		require.Equal(t, "$scheme", c.NginxForwardedProto())
+		require.False(t, c.ProxyProtocol())
+		require.Empty(t, c.NginxAccessControlDirectives())
+
+		require.Equal(t, "http://mattermost.example.com", withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(false)}).SiteURL())
+
+		noFQDN := &Config{}
+		noFQDN.SetDefaults()
+		require.Equal(t, "", noFQDN.SiteURL())
+	})
+
+	t.Run("Behind a load balancer", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(false), ProxyMode: NewString(PROXY_MODE_BEHIND_LB), LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10", "10.0.1.0/24"}}})
+		require.NoError(t, c.isValidProxyMode())
+		require.True(t, c.BehindLB())
+		require.Equal(t, "https://mattermost.example.com", c.SiteURL())
+		require.Equal(t, "https", c.NginxForwardedProto())
+		require.False(t, c.ProxyProtocol())
+		require.Equal(t, []string{
+			"set_real_ip_from 10.0.0.10;",
+			"set_real_ip_from 10.0.1.0/24;",
+			"real_ip_header X-Forwarded-For;",
+			"real_ip_recursive on;",
+		}, c.NginxAccessControlDirectives())
+	})
+
+	t.Run("PROXY protocol", func(t *testing.T) {
+		c := withDefaults(&Config{
+			FQDN:         NewString("mattermost.example.com"),
+			HTTPS:        NewBool(true),
+			Email:        NewString("admin@example.com"),
+			TLS:          &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED)},
+			ProxyMode:    NewString(PROXY_MODE_BEHIND_LB),
+			LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10"}, ProxyProtocol: NewBool(true), SiteURLScheme: NewString("http")},
+		})
+		require.NoError(t, c.isValidProxyMode())
+		require.True(t, c.ProxyProtocol())
+		require.Equal(t, "http://mattermost.example.com", c.SiteURL())
+		require.Contains(t, c.NginxAccessControlDirectives(), "real_ip_header proxy_protocol;")
+	})
+
+	testCases := []struct {
+		Name   string
+		Config *Config
+	}{
+		{
+			Name:   "Unknown proxy mode",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), ProxyMode: NewString("nat")}),
+		},
+		{
+			Name:   "load_balancer requires behind_lb",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10"}}}),
+		},
+		{
+			Name:   "behind_lb requires load_balancer",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), ProxyMode: NewString(PROXY_MODE_BEHIND_LB)}),
+		},
+		{
+			Name:   "Load balancer addresses are required",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(false), ProxyMode: NewString(PROXY_MODE_BEHIND_LB), LoadBalancer: &LoadBalancerConfig{}}),
+		},
+		{
+			Name:   "Load balancer addresses must be IPs or ranges",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(false), ProxyMode: NewString(PROXY_MODE_BEHIND_LB), LoadBalancer: &LoadBalancerConfig{Addresses: []string{"lb.example.com"}}}),
+		},
+		{
+			Name:   "Invalid site_url_scheme",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(false), ProxyMode: NewString(PROXY_MODE_BEHIND_LB), LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10"}, SiteURLScheme: NewString("wss")}}),
+		},
+		{
+			Name:   "Let's Encrypt can't be used behind a load balancer",
+			Config: withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true), ProxyMode: NewString(PROXY_MODE_BEHIND_LB), LoadBalancer: &LoadBalancerConfig{Addresses: []string{"10.0.0.10"}}}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Error(t, tc.Config.isValidProxyMode())
+		})
+	}
+}
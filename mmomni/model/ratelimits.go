@@ -0,0 +1,141 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var nginxRateRegexp = regexp.MustCompile(`^[1-9][0-9]*r/[sm]$`)
+
+// RateLimitsConfig contains the per client IP limits applied by NGINX.
+// Requests over the limits get a 429 response. The connections limit
+// is disabled by default, as clients behind a NAT share the same IP
+type RateLimitsConfig struct {
+	Login       *RateLimitConfig `yaml:"login"`
+	API         *RateLimitConfig `yaml:"api"`
+	Websocket   *RateLimitConfig `yaml:"websocket"`
+	Connections *int             `yaml:"connections"`
+}
+
+// RateLimitConfig is a request rate, e.g. 10r/m or 5r/s, and the
+// burst of requests allowed over it. An empty rate disables the limit
+type RateLimitConfig struct {
+	Rate  *string `yaml:"rate"`
+	Burst *int    `yaml:"burst"`
+}
+
+// RateLimitDirectives contains the rendered rate limit directives for
+// each context of the NGINX configuration
+type RateLimitDirectives struct {
+	HTTP      []string
+	Server    []string
+	Root      []string
+	Websocket []string
+}
+
+func (r *RateLimitConfig) setDefaults(rate string, burst int) {
+	if r.Rate == nil {
+		r.Rate = NewString(rate)
+	}
+
+	if r.Burst == nil {
+		r.Burst = NewInt(burst)
+	}
+}
+
+func (r *RateLimitConfig) IsValid() error {
+	if *r.Rate != "" && !nginxRateRegexp.MatchString(*r.Rate) {
+		return fmt.Errorf("rate must be a number of requests per second or minute, e.g. 10r/m or 5r/s")
+	}
+
+	if *r.Burst < 0 {
+		return fmt.Errorf("burst cannot be negative")
+	}
+
+	return nil
+}
+
+func (r *RateLimitsConfig) SetDefaults() {
+	if r.Login == nil {
+		r.Login = &RateLimitConfig{}
+	}
+	r.Login.setDefaults("10r/m", 10)
+
+	if r.API == nil {
+		r.API = &RateLimitConfig{}
+	}
+	r.API.setDefaults("30r/s", 100)
+
+	if r.Websocket == nil {
+		r.Websocket = &RateLimitConfig{}
+	}
+	r.Websocket.setDefaults("60r/m", 20)
+
+	if r.Connections == nil {
+		r.Connections = NewInt(0)
+	}
+}
+
+func (r *RateLimitsConfig) IsValid() error {
+	limits := map[string]*RateLimitConfig{"login": r.Login, "api": r.API, "websocket": r.Websocket}
+	for name, limit := range limits {
+		if err := limit.IsValid(); err != nil {
+			return fmt.Errorf("invalid %s rate limit: %w", name, err)
+		}
+	}
+
+	if *r.Connections < 0 {
+		return fmt.Errorf("connections cannot be negative")
+	}
+
+	return nil
+}
+
+// limitReq returns the limit_req directive of a zone
+func limitReq(zone string, limit *RateLimitConfig) string {
+	return "limit_req zone=" + zone + " burst=" + strconv.Itoa(*limit.Burst) + " nodelay;"
+}
+
+// NginxRateLimitDirectives renders the rate_limits of the nginx
+// section. The login and API limits are keyed by a map on the request
+// path, so requests to other paths are not accounted
+func (c *Config) NginxRateLimitDirectives() *RateLimitDirectives {
+	directives := &RateLimitDirectives{HTTP: []string{}, Server: []string{}, Root: []string{}, Websocket: []string{}}
+	if c.Nginx == nil || c.Nginx.RateLimits == nil {
+		return directives
+	}
+
+	limits := c.Nginx.RateLimits
+	if *limits.Login.Rate != "" {
+		directives.HTTP = append(directives.HTTP,
+			`map $uri $mattermost_login_limit_key { ~^`+c.NginxSubpathRegex()+`/api/v[0-9]+/users/login $binary_remote_addr; default ""; }`,
+			"limit_req_zone $mattermost_login_limit_key zone=mattermost_login:10m rate="+*limits.Login.Rate+";",
+		)
+		directives.Root = append(directives.Root, limitReq("mattermost_login", limits.Login))
+	}
+
+	if *limits.API.Rate != "" {
+		directives.HTTP = append(directives.HTTP,
+			`map $uri $mattermost_api_limit_key { ~^`+c.NginxSubpathRegex()+`/api/ $binary_remote_addr; default ""; }`,
+			"limit_req_zone $mattermost_api_limit_key zone=mattermost_api:10m rate="+*limits.API.Rate+";",
+		)
+		directives.Root = append(directives.Root, limitReq("mattermost_api", limits.API))
+	}
+
+	if *limits.Websocket.Rate != "" {
+		directives.HTTP = append(directives.HTTP, "limit_req_zone $binary_remote_addr zone=mattermost_websocket:10m rate="+*limits.Websocket.Rate+";")
+		directives.Websocket = append(directives.Websocket, limitReq("mattermost_websocket", limits.Websocket))
+	}
+
+	directives.Server = append(directives.Server, "limit_req_status 429;")
+	if *limits.Connections > 0 {
+		directives.HTTP = append(directives.HTTP, "limit_conn_zone $binary_remote_addr zone=mattermost_conn:10m;")
+		directives.Server = append(directives.Server,
+			"limit_conn_status 429;",
+			"limit_conn mattermost_conn "+strconv.Itoa(*limits.Connections)+";",
+		)
+	}
+
+	return directives
+}
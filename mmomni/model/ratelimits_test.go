@@ -0,0 +1,82 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimits(t *testing.T) {
+	t.Run("No directives without rate_limits", func(t *testing.T) {
+		c := &Config{Nginx: &NginxConfig{}}
+		c.SetDefaults()
+
+		directives := c.NginxRateLimitDirectives()
+		require.Empty(t, directives.HTTP)
+		require.Empty(t, directives.Server)
+		require.Empty(t, directives.Root)
+		require.Empty(t, directives.Websocket)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		c := &Config{Nginx: &NginxConfig{RateLimits: &RateLimitsConfig{}}}
+		c.SetDefaults()
+		require.NoError(t, c.Nginx.IsValid())
+
+		directives := c.NginxRateLimitDirectives()
+		require.Contains(t, directives.HTTP, "limit_req_zone $mattermost_login_limit_key zone=mattermost_login:10m rate=10r/m;")
+		require.Contains(t, directives.HTTP, "limit_req_zone $binary_remote_addr zone=mattermost_websocket:10m rate=60r/m;")
+		require.NotContains(t, directives.HTTP, "limit_conn_zone $binary_remote_addr zone=mattermost_conn:10m;")
+		require.Equal(t, []string{"limit_req_status 429;"}, directives.Server)
+		require.Equal(t, []string{
+			"limit_req zone=mattermost_login burst=10 nodelay;",
+			"limit_req zone=mattermost_api burst=100 nodelay;",
+		}, directives.Root)
+		require.Equal(t, []string{"limit_req zone=mattermost_websocket burst=20 nodelay;"}, directives.Websocket)
+	})
+
+	t.Run("Disabled limits and connections", func(t *testing.T) {
+		c := &Config{Nginx: &NginxConfig{RateLimits: &RateLimitsConfig{
+			API:         &RateLimitConfig{Rate: NewString("")},
+			Websocket:   &RateLimitConfig{Rate: NewString("")},
+			Connections: NewInt(50),
+		}}}
+		c.SetDefaults()
+		require.NoError(t, c.Nginx.IsValid())
+
+		directives := c.NginxRateLimitDirectives()
+		require.Equal(t, []string{"limit_req zone=mattermost_login burst=10 nodelay;"}, directives.Root)
+		require.Empty(t, directives.Websocket)
+		require.Contains(t, directives.HTTP, "limit_conn_zone $binary_remote_addr zone=mattermost_conn:10m;")
+		require.Equal(t, []string{"limit_req_status 429;", "limit_conn_status 429;", "limit_conn mattermost_conn 50;"}, directives.Server)
+	})
+
+	testCases := []struct {
+		Name       string
+		RateLimits *RateLimitsConfig
+	}{
+		{
+			Name:       "Invalid rate",
+			RateLimits: &RateLimitsConfig{Login: &RateLimitConfig{Rate: NewString("10/m")}},
+		},
+		{
+			Name:       "Rate unit must be seconds or minutes",
+			RateLimits: &RateLimitsConfig{API: &RateLimitConfig{Rate: NewString("100r/h")}},
+		},
+		{
+			Name:       "Negative burst",
+			RateLimits: &RateLimitsConfig{Websocket: &RateLimitConfig{Burst: NewInt(-1)}},
+		},
+		{
+			Name:       "Negative connections",
+			RateLimits: &RateLimitsConfig{Connections: NewInt(-1)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.RateLimits.SetDefaults()
+			require.Error(t, tc.RateLimits.IsValid())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SECRETSPATH      = "/etc/mattermost/mmomni.secrets.yml"
+	SECRETS_FILENAME = "mmomni.secrets.yml"
+)
+
+// Secrets contains the sensitive values of the configuration. They
+// are stored in a file only readable by root instead of in the
+// configuration file, which references it through secrets_file
+type Secrets struct {
+	DBPassword        *string `yaml:"db_password,omitempty"`
+	SMTPPassword      *string `yaml:"smtp_password,omitempty"`
+	S3SecretAccessKey *string `yaml:"s3_secret_access_key,omitempty"`
+	ACMEEABHMACKey    *string `yaml:"acme_eab_hmac_key,omitempty"`
+}
+
+// defaultSecretsPath returns the path of the secrets file that sits
+// next to the configuration file at path
+func defaultSecretsPath(path string) string {
+	if path == "" {
+		return SECRETSPATH
+	}
+	return filepath.Join(filepath.Dir(path), SECRETS_FILENAME)
+}
+
+// inlineSecrets returns the keys of the secrets that are set in the
+// configuration file instead of in the secrets file
+func (c *Config) inlineSecrets() []string {
+	keys := []string{}
+	if c.DBPassword != nil {
+		keys = append(keys, "db_password")
+	}
+	if c.SMTP != nil && c.SMTP.Password != nil {
+		keys = append(keys, "smtp.password")
+	}
+	if c.FileStorage != nil && c.FileStorage.S3 != nil && c.FileStorage.S3.SecretAccessKey != nil {
+		keys = append(keys, "file_storage.s3.secret_access_key")
+	}
+	if c.TLS != nil && c.TLS.ACME != nil && c.TLS.ACME.EABHMACKey != nil {
+		keys = append(keys, "tls.acme.eab_hmac_key")
+	}
+	return keys
+}
+
+// LoadSecrets loads the values of the secrets file at path into the
+// config. A missing secrets file is not an error, as the secrets may
+// still be in the configuration file or not be set yet
+func (c *Config) LoadSecrets(path string) error {
+	fileBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var secrets Secrets
+	if err := yaml.Unmarshal(fileBytes, &secrets); err != nil {
+		return err
+	}
+
+	if secrets.DBPassword != nil {
+		c.DBPassword = secrets.DBPassword
+	}
+
+	if secrets.SMTPPassword != nil && c.SMTP != nil {
+		c.SMTP.Password = secrets.SMTPPassword
+	}
+
+	if secrets.S3SecretAccessKey != nil && c.FileStorage != nil && c.FileStorage.S3 != nil {
+		c.FileStorage.S3.SecretAccessKey = secrets.S3SecretAccessKey
+	}
+
+	if secrets.ACMEEABHMACKey != nil && c.TLS != nil && c.TLS.ACME != nil {
+		c.TLS.ACME.EABHMACKey = secrets.ACMEEABHMACKey
+	}
+
+	return nil
+}
+
+func (c *Config) secrets() *Secrets {
+	secrets := &Secrets{
+		DBPassword: c.DBPassword,
+	}
+
+	if c.SMTP != nil && c.SMTP.Password != nil && *c.SMTP.Password != "" {
+		secrets.SMTPPassword = c.SMTP.Password
+	}
+
+	if c.FileStorage != nil && c.FileStorage.S3 != nil && c.FileStorage.S3.SecretAccessKey != nil && *c.FileStorage.S3.SecretAccessKey != "" {
+		secrets.S3SecretAccessKey = c.FileStorage.S3.SecretAccessKey
+	}
+
+	if c.TLS != nil && c.TLS.ACME != nil && c.TLS.ACME.EABHMACKey != nil && *c.TLS.ACME.EABHMACKey != "" {
+		secrets.ACMEEABHMACKey = c.TLS.ACME.EABHMACKey
+	}
+
+	return secrets
+}
+
+func (c *Config) writeSecrets() error {
+	if err := os.MkdirAll(filepath.Dir(*c.SecretsFile), 0755); err != nil {
+		return err
+	}
+
+	secretsBytes, err := yaml.Marshal(c.secrets())
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(*c.SecretsFile, secretsBytes, 0600); err != nil {
+		return err
+	}
+
+	// permissions are enforced even if the file already existed, as
+	// the secrets shouldn't be readable by anyone but root
+	return os.Chmod(*c.SecretsFile, 0600)
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmomni_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mmomni.yml")
+	secretsPath := filepath.Join(dir, SECRETS_FILENAME)
+
+	t.Run("passwords in the configuration file should be moved to the secrets file", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("db_user: mmuser\ndb_password: legacy-password\n"), 0640))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "legacy-password", *config.DBPassword)
+		require.Equal(t, secretsPath, *config.SecretsFile)
+		require.Contains(t, config.Changes(), "migrated configuration to version 2: move db_password to the secrets file")
+		require.NoError(t, config.Save())
+
+		fileBytes, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(fileBytes), "legacy-password")
+		require.Contains(t, string(fileBytes), "secrets_file: "+secretsPath)
+		require.Contains(t, string(fileBytes), "config_version: 2")
+
+		secretsBytes, err := ioutil.ReadFile(secretsPath)
+		require.NoError(t, err)
+		require.Contains(t, string(secretsBytes), "db_password: legacy-password")
+
+		stat, err := os.Stat(secretsPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	})
+
+	t.Run("passwords should be read from the secrets file", func(t *testing.T) {
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "legacy-password", *config.DBPassword)
+		require.Empty(t, config.Changes())
+	})
+
+	t.Run("secrets file permissions should be enforced", func(t *testing.T) {
+		require.NoError(t, os.Chmod(secretsPath, 0644))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		config.DBPassword = NewString("new-password")
+		require.NoError(t, config.Save())
+
+		stat, err := os.Stat(secretsPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+		config, err = ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "new-password", *config.DBPassword)
+	})
+
+	t.Run("the smtp password should be stored in the secrets file", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("db_user: mmuser\nsmtp:\n  host: smtp.example.com\n  username: user\n  password: smtp-password\n  from_address: noreply@example.com\n"), 0640))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "new-password", *config.DBPassword)
+		require.Equal(t, "smtp-password", *config.SMTP.Password)
+		require.Contains(t, config.Changes(), "smtp.password will be moved to the secrets file")
+		require.NoError(t, config.Save())
+
+		fileBytes, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(fileBytes), "smtp-password")
+
+		config, err = ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "smtp-password", *config.SMTP.Password)
+	})
+
+	t.Run("the acme eab hmac key should be stored in the secrets file", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("db_user: mmuser\ntls:\n  acme:\n    eab_kid: kid\n    eab_hmac_key: eab-hmac-key\n"), 0640))
+
+		config, err := ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "eab-hmac-key", *config.TLS.ACME.EABHMACKey)
+		require.Contains(t, config.Changes(), "tls.acme.eab_hmac_key will be moved to the secrets file")
+		require.NoError(t, config.Save())
+
+		fileBytes, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(fileBytes), "eab-hmac-key")
+
+		config, err = ReadConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, "eab-hmac-key", *config.TLS.ACME.EABHMACKey)
+	})
+}
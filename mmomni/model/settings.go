@@ -0,0 +1,233 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MATTERMOST_DEFAULT_CONFIG is the default Mattermost configuration
+// shipped with the mattermost package, used to validate the keys of
+// mattermost_settings
+const MATTERMOST_DEFAULT_CONFIG = "/opt/mattermost/config/config.defaults.json"
+
+// omnibusSettings contains the Mattermost settings that Omnibus always
+// generates from its own configuration
+var omnibusSettings = []string{
+	"SqlSettings.DataSource",
+	"ServiceSettings.SiteURL",
+	"ServiceSettings.ListenAddress",
+	"ServiceSettings.Forward80To443",
+	"ServiceSettings.UseLetsEncrypt",
+	"ServiceSettings.ConnectionSecurity",
+	"ServiceSettings.EnableLocalMode",
+	"FileSettings.Directory",
+	"PluginSettings.EnableUploads",
+	"LogSettings.FileLocation",
+}
+
+// EnvVariable is an environment variable rendered into the Mattermost
+// environment file
+type EnvVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// flattenSettings converts a nested settings map into a map indexed by
+// the dot separated path of each setting, e.g. EmailSettings.SMTPServer
+func flattenSettings(prefix string, settings map[string]interface{}, out map[string]interface{}) {
+	for key, value := range settings {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenSettings(path, nested, out)
+			continue
+		}
+		out[path] = value
+	}
+}
+
+func settingEnvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		// Mattermost reads list settings from the environment as
+		// space separated values
+		items := make([]string, len(v))
+		for i, item := range v {
+			itemValue, err := settingEnvValue(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(itemValue, " ") {
+				return "", fmt.Errorf("list items cannot contain spaces")
+			}
+			items[i] = itemValue
+		}
+		return strings.Join(items, " "), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
+
+// quoteEnvValue quotes a value so it can be safely read from a systemd
+// environment file
+func quoteEnvValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// MattermostEnv returns the environment variables that override the
+// Mattermost settings set in mattermost_settings, sorted by name
+func (c *Config) MattermostEnv() ([]*EnvVariable, error) {
+	settings := map[string]interface{}{}
+	flattenSettings("", c.MattermostSettings, settings)
+
+	env := []*EnvVariable{}
+	for path, value := range settings {
+		envValue, err := settingEnvValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for mattermost setting %q: %w", path, err)
+		}
+		if strings.ContainsAny(envValue, "\n\r") {
+			return nil, fmt.Errorf("invalid value for mattermost setting %q: values cannot contain line breaks", path)
+		}
+
+		env = append(env, &EnvVariable{
+			Name:  "MM_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+			Value: quoteEnvValue(envValue),
+		})
+	}
+
+	sort.Slice(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+
+	return env, nil
+}
+
+// managedSettings returns the Mattermost settings that Omnibus
+// generates from its own configuration, so they cannot be set through
+// mattermost_settings
+func (c *Config) managedSettings() []string {
+	settings := append([]string{}, omnibusSettings...)
+	if c.SMTP != nil {
+		settings = append(settings, c.SMTP.managedSettings()...)
+	}
+	if c.FileStorage != nil {
+		settings = append(settings, c.FileStorage.managedSettings()...)
+	}
+	if c.Database != nil {
+		settings = append(settings, c.Database.managedSettings()...)
+	}
+	if c.Postgres != nil {
+		settings = append(settings, c.Postgres.managedSettings()...)
+	}
+	return settings
+}
+
+func (c *Config) isValidMattermostSettings() error {
+	settings := map[string]interface{}{}
+	flattenSettings("", c.MattermostSettings, settings)
+
+	for _, managed := range c.managedSettings() {
+		if _, ok := settings[managed]; ok {
+			return fmt.Errorf("mattermost setting %q is managed by Omnibus and cannot be set in mattermost_settings", managed)
+		}
+	}
+
+	if _, err := c.MattermostEnv(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadMattermostDefaults reads the default Mattermost configuration
+func ReadMattermostDefaults(path string) (map[string]interface{}, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := map[string]interface{}{}
+	if err := json.Unmarshal(fileBytes, &defaults); err != nil {
+		return nil, err
+	}
+
+	return defaults, nil
+}
+
+// ValidateMattermostSettings checks that all the settings exist in the
+// default Mattermost configuration and that their values have the
+// same type as the default ones
+func ValidateMattermostSettings(settings, defaults map[string]interface{}) error {
+	flatSettings := map[string]interface{}{}
+	flattenSettings("", settings, flatSettings)
+
+	paths := make([]string, 0, len(flatSettings))
+	for path := range flatSettings {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		var defaultValue interface{} = defaults
+		for _, key := range strings.Split(path, ".") {
+			section, ok := defaultValue.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unknown mattermost setting %q", path)
+			}
+			if defaultValue, ok = section[key]; !ok {
+				return fmt.Errorf("unknown mattermost setting %q", path)
+			}
+		}
+
+		if !sameSettingType(flatSettings[path], defaultValue) {
+			return fmt.Errorf("invalid value for mattermost setting %q: expected a value of type %s", path, settingType(defaultValue))
+		}
+	}
+
+	return nil
+}
+
+func settingType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, float64:
+		return "number"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "section"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sameSettingType(value, defaultValue interface{}) bool {
+	// settings without a default value accept any scalar or list
+	if defaultValue == nil {
+		return settingType(value) != "section"
+	}
+	return settingType(value) == settingType(defaultValue)
+}
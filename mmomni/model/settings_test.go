@@ -0,0 +1,120 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func settingsFromYAML(t *testing.T, contents string) map[string]interface{} {
+	settings := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal([]byte(contents), &settings))
+	return settings
+}
+
+func TestMattermostEnv(t *testing.T) {
+	t.Run("settings should be rendered as sorted environment variables", func(t *testing.T) {
+		config := &Config{MattermostSettings: settingsFromYAML(t, `
+EmailSettings:
+  SMTPServer: smtp.example.com
+  SMTPPort: 587
+  SendEmailNotifications: true
+  FeedbackName: 'The "Team"'
+ServiceSettings:
+  AllowCorsFrom: ""
+  TrustedProxyIPHeader: [X-Forwarded-For, X-Real-IP]
+`)}
+
+		env, err := config.MattermostEnv()
+		require.NoError(t, err)
+		require.Equal(t, []*EnvVariable{
+			{Name: "MM_EMAILSETTINGS_FEEDBACKNAME", Value: `"The \"Team\""`},
+			{Name: "MM_EMAILSETTINGS_SENDEMAILNOTIFICATIONS", Value: `"true"`},
+			{Name: "MM_EMAILSETTINGS_SMTPPORT", Value: `"587"`},
+			{Name: "MM_EMAILSETTINGS_SMTPSERVER", Value: `"smtp.example.com"`},
+			{Name: "MM_SERVICESETTINGS_ALLOWCORSFROM", Value: `""`},
+			{Name: "MM_SERVICESETTINGS_TRUSTEDPROXYIPHEADER", Value: `"X-Forwarded-For X-Real-IP"`},
+		}, env)
+	})
+
+	t.Run("values with line breaks should be rejected", func(t *testing.T) {
+		config := &Config{MattermostSettings: settingsFromYAML(t, "EmailSettings:\n  FeedbackName: \"a\\nb\"\n")}
+		_, err := config.MattermostEnv()
+		require.Error(t, err)
+	})
+}
+
+func TestIsValidMattermostSettings(t *testing.T) {
+	t.Run("settings managed by Omnibus should be rejected", func(t *testing.T) {
+		config := &Config{MattermostSettings: settingsFromYAML(t, "ServiceSettings:\n  SiteURL: https://example.com\n")}
+		err := config.isValidMattermostSettings()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "ServiceSettings.SiteURL")
+	})
+
+	t.Run("other settings should be accepted", func(t *testing.T) {
+		config := &Config{MattermostSettings: settingsFromYAML(t, "ServiceSettings:\n  EnableCustomEmoji: true\n")}
+		require.NoError(t, config.isValidMattermostSettings())
+	})
+}
+
+func TestValidateMattermostSettings(t *testing.T) {
+	defaults := settingsFromYAML(t, `
+EmailSettings:
+  SMTPServer: ""
+  SMTPPort: 10025
+  SendEmailNotifications: false
+ServiceSettings:
+  TrustedProxyIPHeader: []
+  GoogleDeveloperKey: null
+`)
+
+	testCases := []struct {
+		name          string
+		settings      string
+		expectedError string
+	}{
+		{
+			name:     "Known settings with the right types",
+			settings: "EmailSettings:\n  SMTPServer: smtp.example.com\n  SMTPPort: 25\n  SendEmailNotifications: true\nServiceSettings:\n  TrustedProxyIPHeader: [X-Real-IP]\n  GoogleDeveloperKey: key\n",
+		},
+		{
+			name:          "Unknown setting",
+			settings:      "EmailSettings:\n  SMTPHost: smtp.example.com\n",
+			expectedError: `unknown mattermost setting "EmailSettings.SMTPHost"`,
+		},
+		{
+			name:          "Unknown section",
+			settings:      "MailSettings:\n  SMTPServer: smtp.example.com\n",
+			expectedError: `unknown mattermost setting "MailSettings.SMTPServer"`,
+		},
+		{
+			name:          "Setting a whole section",
+			settings:      "EmailSettings: none\n",
+			expectedError: `expected a value of type section`,
+		},
+		{
+			name:          "Wrong type",
+			settings:      "EmailSettings:\n  SMTPPort: \"25\"\n",
+			expectedError: `expected a value of type number`,
+		},
+		{
+			name:          "Too deep",
+			settings:      "EmailSettings:\n  SMTPServer:\n    Host: smtp.example.com\n",
+			expectedError: `unknown mattermost setting "EmailSettings.SMTPServer.Host"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMattermostSettings(settingsFromYAML(t, tc.settings), defaults)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
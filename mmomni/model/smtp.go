@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"strconv"
+)
+
+const (
+	SMTP_SECURITY_NONE     = "none"
+	SMTP_SECURITY_TLS      = "tls"
+	SMTP_SECURITY_STARTTLS = "starttls"
+)
+
+// SMTPConfig contains the settings of the server used by Mattermost to
+// send email notifications. The password is stored in the secrets file
+type SMTPConfig struct {
+	Host        *string `yaml:"host"`
+	Port        *int    `yaml:"port"`
+	Username    *string `yaml:"username"`
+	Password    *string `yaml:"password,omitempty"`
+	Security    *string `yaml:"security"`
+	FromAddress *string `yaml:"from_address"`
+}
+
+func (s *SMTPConfig) SetDefaults() {
+	if s.Host == nil {
+		s.Host = NewString("")
+	}
+
+	if s.Port == nil {
+		s.Port = NewInt(587)
+	}
+
+	if s.Username == nil {
+		s.Username = NewString("")
+	}
+
+	if s.Security == nil {
+		s.Security = NewString(SMTP_SECURITY_STARTTLS)
+	}
+
+	if s.FromAddress == nil {
+		s.FromAddress = NewString("")
+	}
+}
+
+// IsEnabled returns true if a SMTP server has been configured
+func (s *SMTPConfig) IsEnabled() bool {
+	return s != nil && s.Host != nil && *s.Host != ""
+}
+
+func (s *SMTPConfig) IsValid() error {
+	if !s.IsEnabled() {
+		return nil
+	}
+
+	if *s.Port < 1 || *s.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+
+	switch *s.Security {
+	case SMTP_SECURITY_NONE, SMTP_SECURITY_TLS, SMTP_SECURITY_STARTTLS:
+	default:
+		return fmt.Errorf("security must be one of %q, %q or %q", SMTP_SECURITY_NONE, SMTP_SECURITY_TLS, SMTP_SECURITY_STARTTLS)
+	}
+
+	if *s.FromAddress == "" {
+		return fmt.Errorf("from_address cannot be empty")
+	}
+
+	if _, err := mail.ParseAddress(*s.FromAddress); err != nil {
+		return fmt.Errorf("invalid from_address %q: %w", *s.FromAddress, err)
+	}
+
+	if *s.Username != "" && (s.Password == nil || *s.Password == "") {
+		return fmt.Errorf("password must be set if username is set")
+	}
+
+	return nil
+}
+
+// connectionSecurity returns the value of the Mattermost
+// ConnectionSecurity setting for the configured security
+func (s *SMTPConfig) connectionSecurity() string {
+	switch *s.Security {
+	case SMTP_SECURITY_TLS:
+		return "TLS"
+	case SMTP_SECURITY_STARTTLS:
+		return "STARTTLS"
+	}
+	return ""
+}
+
+// Env returns the environment variables that configure Mattermost to
+// send emails through the SMTP server
+func (s *SMTPConfig) Env() []*EnvVariable {
+	if !s.IsEnabled() {
+		return []*EnvVariable{}
+	}
+
+	password := ""
+	if s.Password != nil {
+		password = *s.Password
+	}
+
+	// FeedbackEmail only accepts the address, so the name of the from
+	// address is set as FeedbackName
+	feedbackEmail, feedbackName := *s.FromAddress, ""
+	if address, err := mail.ParseAddress(*s.FromAddress); err == nil {
+		feedbackEmail, feedbackName = address.Address, address.Name
+	}
+
+	values := []struct{ name, value string }{
+		{"MM_EMAILSETTINGS_SENDEMAILNOTIFICATIONS", "true"},
+		{"MM_EMAILSETTINGS_SMTPSERVER", *s.Host},
+		{"MM_EMAILSETTINGS_SMTPPORT", strconv.Itoa(*s.Port)},
+		{"MM_EMAILSETTINGS_CONNECTIONSECURITY", s.connectionSecurity()},
+		{"MM_EMAILSETTINGS_ENABLESMTPAUTH", strconv.FormatBool(*s.Username != "")},
+		{"MM_EMAILSETTINGS_SMTPUSERNAME", *s.Username},
+		{"MM_EMAILSETTINGS_SMTPPASSWORD", password},
+		{"MM_EMAILSETTINGS_FEEDBACKEMAIL", feedbackEmail},
+	}
+	if feedbackName != "" {
+		values = append(values, struct{ name, value string }{"MM_EMAILSETTINGS_FEEDBACKNAME", feedbackName})
+	}
+
+	env := make([]*EnvVariable, len(values))
+	for i, v := range values {
+		env[i] = &EnvVariable{Name: v.name, Value: quoteEnvValue(v.value)}
+	}
+
+	return env
+}
+
+// managedSettings returns the Mattermost settings that the SMTP
+// configuration sets
+func (s *SMTPConfig) managedSettings() []string {
+	if !s.IsEnabled() {
+		return []string{}
+	}
+
+	settings := []string{
+		"EmailSettings.SendEmailNotifications",
+		"EmailSettings.SMTPServer",
+		"EmailSettings.SMTPPort",
+		"EmailSettings.ConnectionSecurity",
+		"EmailSettings.EnableSMTPAuth",
+		"EmailSettings.SMTPUsername",
+		"EmailSettings.SMTPPassword",
+		"EmailSettings.FeedbackEmail",
+	}
+	if s.FromAddress != nil {
+		if address, err := mail.ParseAddress(*s.FromAddress); err == nil && address.Name != "" {
+			settings = append(settings, "EmailSettings.FeedbackName")
+		}
+	}
+	return settings
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPConfigIsValid(t *testing.T) {
+	baseConfig := func() *SMTPConfig {
+		s := &SMTPConfig{
+			Host:        NewString("smtp.example.com"),
+			FromAddress: NewString("Mattermost <noreply@example.com>"),
+		}
+		s.SetDefaults()
+		return s
+	}
+
+	testCases := []struct {
+		name          string
+		modify        func(s *SMTPConfig)
+		expectedError string
+	}{
+		{
+			name:   "Valid configuration",
+			modify: func(_ *SMTPConfig) {},
+		},
+		{
+			name:   "An empty host disables the validation",
+			modify: func(s *SMTPConfig) { s.Host = NewString(""); s.FromAddress = NewString("") },
+		},
+		{
+			name:          "Invalid port",
+			modify:        func(s *SMTPConfig) { s.Port = NewInt(0) },
+			expectedError: "port must be between 1 and 65535",
+		},
+		{
+			name:          "Invalid security",
+			modify:        func(s *SMTPConfig) { s.Security = NewString("ssl") },
+			expectedError: "security must be one of",
+		},
+		{
+			name:          "Empty from address",
+			modify:        func(s *SMTPConfig) { s.FromAddress = NewString("") },
+			expectedError: "from_address cannot be empty",
+		},
+		{
+			name:          "Invalid from address",
+			modify:        func(s *SMTPConfig) { s.FromAddress = NewString("not an address") },
+			expectedError: "invalid from_address",
+		},
+		{
+			name:          "Username without password",
+			modify:        func(s *SMTPConfig) { s.Username = NewString("user") },
+			expectedError: "password must be set",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := baseConfig()
+			tc.modify(s)
+			err := s.IsValid()
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestSMTPConfigEnv(t *testing.T) {
+	t.Run("A disabled SMTP configuration should not generate variables", func(t *testing.T) {
+		s := &SMTPConfig{}
+		s.SetDefaults()
+		require.Empty(t, s.Env())
+	})
+
+	t.Run("An enabled SMTP configuration should generate the email settings", func(t *testing.T) {
+		s := &SMTPConfig{
+			Host:        NewString("smtp.example.com"),
+			Username:    NewString("user"),
+			Password:    NewString(`pa"ss`),
+			FromAddress: NewString("noreply@example.com"),
+		}
+		s.SetDefaults()
+
+		env := map[string]string{}
+		for _, variable := range s.Env() {
+			env[variable.Name] = variable.Value
+		}
+
+		require.Equal(t, `"smtp.example.com"`, env["MM_EMAILSETTINGS_SMTPSERVER"])
+		require.Equal(t, `"587"`, env["MM_EMAILSETTINGS_SMTPPORT"])
+		require.Equal(t, `"STARTTLS"`, env["MM_EMAILSETTINGS_CONNECTIONSECURITY"])
+		require.Equal(t, `"true"`, env["MM_EMAILSETTINGS_ENABLESMTPAUTH"])
+		require.Equal(t, `"pa\"ss"`, env["MM_EMAILSETTINGS_SMTPPASSWORD"])
+		require.Equal(t, `"noreply@example.com"`, env["MM_EMAILSETTINGS_FEEDBACKEMAIL"])
+		require.NotContains(t, env, "MM_EMAILSETTINGS_FEEDBACKNAME")
+	})
+
+	t.Run("A from address with a name should set the feedback name", func(t *testing.T) {
+		s := &SMTPConfig{
+			Host:        NewString("smtp.example.com"),
+			FromAddress: NewString("Mattermost <noreply@example.com>"),
+		}
+		s.SetDefaults()
+
+		env := map[string]string{}
+		for _, variable := range s.Env() {
+			env[variable.Name] = variable.Value
+		}
+
+		require.Equal(t, `"noreply@example.com"`, env["MM_EMAILSETTINGS_FEEDBACKEMAIL"])
+		require.Equal(t, `"Mattermost"`, env["MM_EMAILSETTINGS_FEEDBACKNAME"])
+		require.Contains(t, s.managedSettings(), "EmailSettings.FeedbackName")
+	})
+
+	t.Run("Email settings should not be set through mattermost_settings if SMTP is enabled", func(t *testing.T) {
+		config := &Config{
+			SMTP:               &SMTPConfig{Host: NewString("smtp.example.com")},
+			MattermostSettings: map[string]interface{}{"EmailSettings": map[string]interface{}{"SMTPServer": "other.example.com"}},
+		}
+		err := config.isValidMattermostSettings()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "EmailSettings.SMTPServer")
+	})
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var subpathRegexp = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// isValidSubpath checks that the subpath is empty or an URL path
+// without a trailing slash, e.g. /chat
+func (c *Config) isValidSubpath() error {
+	if *c.Subpath != "" && !subpathRegexp.MatchString(*c.Subpath) {
+		return fmt.Errorf("subpath must be an URL path starting with a slash and without a trailing slash, e.g. /chat")
+	}
+	return nil
+}
+
+// NginxSubpathRegex returns the subpath quoted to be used as the
+// prefix of the regular expressions of the NGINX configuration
+func (c *Config) NginxSubpathRegex() string {
+	return regexp.QuoteMeta(*c.Subpath)
+}
+
+// TrimSubpath removes the subpath from a request path
+func (c *Config) TrimSubpath(path string) string {
+	if *c.Subpath == "" || !strings.HasPrefix(path, *c.Subpath+"/") {
+		return path
+	}
+	return strings.TrimPrefix(path, *c.Subpath)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubpath(t *testing.T) {
+	c := withDefaults(&Config{FQDN: NewString("example.com"), HTTPS: NewBool(true), Subpath: NewString("/chat.v2")})
+	require.NoError(t, c.isValidSubpath())
+	require.Equal(t, "https://example.com/chat.v2", c.SiteURL())
+	require.Equal(t, "https://example.com", c.SiteURLBase(), "redirects append $request_uri, which includes the subpath")
+	require.Equal(t, `/chat\.v2`, c.NginxSubpathRegex())
+	require.Equal(t, "/api/v4/users/login", c.TrimSubpath("/chat.v2/api/v4/users/login"))
+	require.Equal(t, "/chat.v2x/api", c.TrimSubpath("/chat.v2x/api"))
+
+	c.Nginx = &NginxConfig{RateLimits: &RateLimitsConfig{}}
+	c.SetDefaults()
+	require.Contains(t, c.NginxRateLimitDirectives().HTTP, `map $uri $mattermost_api_limit_key { ~^/chat\.v2/api/ $binary_remote_addr; default ""; }`)
+
+	noSubpath := withDefaults(&Config{FQDN: NewString("example.com"), HTTPS: NewBool(true), Subpath: NewString("")})
+	require.NoError(t, noSubpath.isValidSubpath())
+	require.Equal(t, "https://example.com", noSubpath.SiteURL())
+	require.Equal(t, "/api/v4/users/login", noSubpath.TrimSubpath("/api/v4/users/login"))
+
+	for _, subpath := range []string{"chat", "/chat/", "/", "//chat", "/chat room", "/chat;"} {
+		t.Run(subpath, func(t *testing.T) {
+			require.Error(t, withDefaults(&Config{FQDN: NewString("example.com"), HTTPS: NewBool(true), Subpath: NewString(subpath)}).isValidSubpath())
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+const (
+	TLS_MODE_LETSENCRYPT = "letsencrypt"
+	TLS_MODE_CUSTOM      = "custom"
+	TLS_MODE_SELFSIGNED  = "selfsigned"
+
+	// TLS_DIR contains the certificates that Omnibus generates or
+	// assembles for NGINX
+	TLS_DIR = "/etc/mattermost/tls"
+
+	LETSENCRYPT_LIVE_DIR = "/etc/letsencrypt/live"
+
+	ACME_CHALLENGE_HTTP = "http"
+	ACME_CHALLENGE_DNS  = "dns"
+)
+
+var dnsPluginRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// TLSConfig contains the source of the certificate that NGINX uses
+// when https is enabled. The custom mode uses the certificate and key
+// files provided, and the selfsigned mode generates a local CA and a
+// certificate signed by it
+type TLSConfig struct {
+	Mode      *string `yaml:"mode"`
+	CertFile  *string `yaml:"cert_file,omitempty"`
+	KeyFile   *string `yaml:"key_file,omitempty"`
+	ChainFile *string `yaml:"chain_file,omitempty"`
+
+	ACME *ACMEConfig `yaml:"acme,omitempty"`
+}
+
+// ACMEConfig customizes how certbot gets the certificate in the
+// letsencrypt mode: the ACME server, the challenge type and the
+// External Account Binding credentials required by some CAs. The EAB
+// HMAC key is stored in the secrets file. The dns challenge requires
+// the certbot package of the DNS plugin, e.g. python3-certbot-dns-rfc2136
+// for the rfc2136 plugin
+type ACMEConfig struct {
+	DirectoryURL          *string `yaml:"directory_url"`
+	Challenge             *string `yaml:"challenge"`
+	DNSPlugin             *string `yaml:"dns_plugin"`
+	DNSCredentialsFile    *string `yaml:"dns_credentials_file"`
+	DNSPropagationSeconds *int    `yaml:"dns_propagation_seconds"`
+	EABKeyID              *string `yaml:"eab_kid"`
+	EABHMACKey            *string `yaml:"eab_hmac_key,omitempty"`
+	CABundle              *string `yaml:"ca_bundle"`
+}
+
+func (t *TLSConfig) SetDefaults() {
+	if t.Mode == nil {
+		t.Mode = NewString(TLS_MODE_LETSENCRYPT)
+	}
+
+	if t.ACME != nil {
+		t.ACME.SetDefaults()
+	}
+}
+
+func (t *TLSConfig) IsValid() error {
+	hasCustomFiles := t.CertFile != nil || t.KeyFile != nil || t.ChainFile != nil
+
+	if t.ACME != nil && *t.Mode != TLS_MODE_LETSENCRYPT {
+		return fmt.Errorf("acme can only be set if mode is %q", TLS_MODE_LETSENCRYPT)
+	}
+
+	switch *t.Mode {
+	case TLS_MODE_LETSENCRYPT:
+		if hasCustomFiles {
+			return fmt.Errorf("cert_file, key_file and chain_file can only be set if mode is %q", TLS_MODE_CUSTOM)
+		}
+		if t.ACME != nil {
+			if err := t.ACME.IsValid(); err != nil {
+				return fmt.Errorf("invalid acme configuration: %w", err)
+			}
+		}
+	case TLS_MODE_SELFSIGNED:
+		if hasCustomFiles {
+			return fmt.Errorf("cert_file, key_file and chain_file can only be set if mode is %q", TLS_MODE_CUSTOM)
+		}
+	case TLS_MODE_CUSTOM:
+		if t.CertFile == nil || *t.CertFile == "" || t.KeyFile == nil || *t.KeyFile == "" {
+			return fmt.Errorf("cert_file and key_file must be set if mode is %q", TLS_MODE_CUSTOM)
+		}
+
+		for _, path := range []*string{t.CertFile, t.KeyFile, t.ChainFile} {
+			if path != nil && !filepath.IsAbs(*path) {
+				return fmt.Errorf("certificate path %q must be absolute", *path)
+			}
+		}
+	default:
+		return fmt.Errorf("mode must be one of %q, %q or %q", TLS_MODE_LETSENCRYPT, TLS_MODE_CUSTOM, TLS_MODE_SELFSIGNED)
+	}
+
+	return nil
+}
+
+func (a *ACMEConfig) SetDefaults() {
+	if a.DirectoryURL == nil {
+		a.DirectoryURL = NewString("")
+	}
+
+	if a.Challenge == nil {
+		a.Challenge = NewString(ACME_CHALLENGE_HTTP)
+	}
+
+	if a.DNSPlugin == nil {
+		a.DNSPlugin = NewString("")
+	}
+
+	if a.DNSCredentialsFile == nil {
+		a.DNSCredentialsFile = NewString("")
+	}
+
+	if a.DNSPropagationSeconds == nil {
+		a.DNSPropagationSeconds = NewInt(0)
+	}
+
+	if a.EABKeyID == nil {
+		a.EABKeyID = NewString("")
+	}
+
+	if a.CABundle == nil {
+		a.CABundle = NewString("")
+	}
+}
+
+func (a *ACMEConfig) IsValid() error {
+	if *a.DirectoryURL != "" {
+		u, err := url.Parse(*a.DirectoryURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("directory_url must be an https URL")
+		}
+	}
+
+	switch *a.Challenge {
+	case ACME_CHALLENGE_HTTP:
+		if *a.DNSPlugin != "" || *a.DNSCredentialsFile != "" || *a.DNSPropagationSeconds != 0 {
+			return fmt.Errorf("dns settings can only be set if challenge is %q", ACME_CHALLENGE_DNS)
+		}
+	case ACME_CHALLENGE_DNS:
+		if !dnsPluginRegexp.MatchString(*a.DNSPlugin) {
+			return fmt.Errorf("dns_plugin must be the name of a certbot DNS plugin, e.g. cloudflare or rfc2136")
+		}
+		if *a.DNSCredentialsFile != "" && !filepath.IsAbs(*a.DNSCredentialsFile) {
+			return fmt.Errorf("dns_credentials_file must be an absolute path")
+		}
+		if *a.DNSPropagationSeconds < 0 {
+			return fmt.Errorf("dns_propagation_seconds cannot be negative")
+		}
+	default:
+		return fmt.Errorf("challenge must be one of %q or %q", ACME_CHALLENGE_HTTP, ACME_CHALLENGE_DNS)
+	}
+
+	hasHMACKey := a.EABHMACKey != nil && *a.EABHMACKey != ""
+	if (*a.EABKeyID != "") != hasHMACKey {
+		return fmt.Errorf("eab_kid and eab_hmac_key must be set together")
+	}
+
+	if *a.CABundle != "" && !filepath.IsAbs(*a.CABundle) {
+		return fmt.Errorf("ca_bundle must be an absolute path")
+	}
+
+	return nil
+}
+
+// CertbotArgs returns the arguments of the certbot command that
+// requests the certificate for the fqdn and its aliases
+func (c *Config) CertbotArgs() []string {
+	// the certificate keeps the fqdn as its name, so its path doesn't
+	// change when aliases are added or removed
+	args := []string{"certonly", "--cert-name", *c.FQDN}
+	for _, hostname := range c.Hostnames() {
+		args = append(args, "-d", hostname)
+	}
+	args = append(args, "--renew-with-new-domains", "-n", "--agree-tos", "--email", *c.Email)
+
+	var acme *ACMEConfig
+	if c.TLS != nil {
+		acme = c.TLS.ACME
+	}
+
+	if acme == nil || *acme.Challenge == ACME_CHALLENGE_HTTP {
+		args = append(args, "--nginx")
+	} else {
+		plugin := "dns-" + *acme.DNSPlugin
+		args = append(args, "--"+plugin)
+		if *acme.DNSCredentialsFile != "" {
+			args = append(args, "--"+plugin+"-credentials", *acme.DNSCredentialsFile)
+		}
+		if *acme.DNSPropagationSeconds != 0 {
+			args = append(args, "--"+plugin+"-propagation-seconds", strconv.Itoa(*acme.DNSPropagationSeconds))
+		}
+	}
+
+	if acme != nil && *acme.DirectoryURL != "" {
+		args = append(args, "--server", *acme.DirectoryURL)
+	}
+
+	if acme != nil && *acme.EABKeyID != "" {
+		args = append(args, "--eab-kid", *acme.EABKeyID, "--eab-hmac-key", *acme.EABHMACKey)
+	}
+
+	return args
+}
+
+// TLSMode returns the configured TLS mode, which defaults to
+// letsencrypt if the tls section is not set
+func (c *Config) TLSMode() string {
+	if c.TLS == nil || c.TLS.Mode == nil {
+		return TLS_MODE_LETSENCRYPT
+	}
+	return *c.TLS.Mode
+}
+
+// SelfSignedDir returns the directory where the local CA and the
+// certificate of the selfsigned mode are stored
+func SelfSignedDir() string {
+	return filepath.Join(TLS_DIR, "selfsigned")
+}
+
+// SelfSignedCA returns the paths of the certificate and the key of
+// the local CA of the selfsigned mode
+func SelfSignedCA() (string, string) {
+	return filepath.Join(SelfSignedDir(), "ca.pem"), filepath.Join(SelfSignedDir(), "ca.key")
+}
+
+// TLSCertificate returns the paths of the certificate, including its
+// chain, and of the key that NGINX serves
+func (c *Config) TLSCertificate() (string, string) {
+	switch c.TLSMode() {
+	case TLS_MODE_CUSTOM:
+		// if a chain is provided, it is appended to the certificate
+		// in a file that reconfigure assembles
+		if c.TLS.ChainFile != nil && *c.TLS.ChainFile != "" {
+			return filepath.Join(TLS_DIR, "custom_fullchain.pem"), *c.TLS.KeyFile
+		}
+		return *c.TLS.CertFile, *c.TLS.KeyFile
+	case TLS_MODE_SELFSIGNED:
+		return filepath.Join(SelfSignedDir(), "fullchain.pem"), filepath.Join(SelfSignedDir(), "privkey.pem")
+	}
+
+	return filepath.Join(LETSENCRYPT_LIVE_DIR, *c.FQDN, "fullchain.pem"), filepath.Join(LETSENCRYPT_LIVE_DIR, *c.FQDN, "privkey.pem")
+}
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		TLS         *TLSConfig
+		ExpectError bool
+	}{
+		{
+			Name: "Letsencrypt is the default mode",
+			TLS:  &TLSConfig{},
+		},
+		{
+			Name: "Custom mode with cert and key is valid",
+			TLS:  &TLSConfig{Mode: NewString(TLS_MODE_CUSTOM), CertFile: NewString("/etc/ssl/cert.pem"), KeyFile: NewString("/etc/ssl/key.pem")},
+		},
+		{
+			Name:        "Custom mode requires the key",
+			TLS:         &TLSConfig{Mode: NewString(TLS_MODE_CUSTOM), CertFile: NewString("/etc/ssl/cert.pem")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Custom mode requires absolute paths",
+			TLS:         &TLSConfig{Mode: NewString(TLS_MODE_CUSTOM), CertFile: NewString("cert.pem"), KeyFile: NewString("/etc/ssl/key.pem")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Certificate files are only valid in custom mode",
+			TLS:         &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED), CertFile: NewString("/etc/ssl/cert.pem")},
+			ExpectError: true,
+		},
+		{
+			Name:        "Unknown modes are invalid",
+			TLS:         &TLSConfig{Mode: NewString("acme")},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.TLS.SetDefaults()
+			err := tc.TLS.IsValid()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTLSCertificate(t *testing.T) {
+	cert, key := withDefaults(&Config{FQDN: NewString("mattermost.example.com")}).TLSCertificate()
+	require.Equal(t, "/etc/letsencrypt/live/mattermost.example.com/fullchain.pem", cert)
+	require.Equal(t, "/etc/letsencrypt/live/mattermost.example.com/privkey.pem", key)
+
+	cert, key = withDefaults(&Config{FQDN: NewString("mattermost.example.com"), TLS: &TLSConfig{Mode: NewString(TLS_MODE_CUSTOM), CertFile: NewString("/etc/ssl/cert.pem"), KeyFile: NewString("/etc/ssl/key.pem")}}).TLSCertificate()
+	require.Equal(t, "/etc/ssl/cert.pem", cert)
+	require.Equal(t, "/etc/ssl/key.pem", key)
+
+	cert, _ = withDefaults(&Config{FQDN: NewString("mattermost.example.com"), TLS: &TLSConfig{Mode: NewString(TLS_MODE_CUSTOM), CertFile: NewString("/etc/ssl/cert.pem"), KeyFile: NewString("/etc/ssl/key.pem"), ChainFile: NewString("/etc/ssl/chain.pem")}}).TLSCertificate()
+	require.Equal(t, "/etc/mattermost/tls/custom_fullchain.pem", cert)
+
+	cert, key = withDefaults(&Config{FQDN: NewString("mattermost.example.com"), TLS: &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED)}}).TLSCertificate()
+	require.Equal(t, "/etc/mattermost/tls/selfsigned/fullchain.pem", cert)
+	require.Equal(t, "/etc/mattermost/tls/selfsigned/privkey.pem", key)
+}
+
+func TestHTTPSRequirements(t *testing.T) {
+	c := &Config{FQDN: NewString("mattermost.example.com"), HTTPS: NewBool(true)}
+	c.SetDefaults()
+	require.Error(t, c.IsValid(), "letsencrypt requires an email")
+
+	c.TLS = &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED)}
+	c.SetDefaults()
+	require.NoError(t, c.IsValid())
+}
+
+func TestACMEConfig(t *testing.T) {
+	newTLS := func(acme *ACMEConfig) *TLSConfig {
+		tls := &TLSConfig{ACME: acme}
+		tls.SetDefaults()
+		return tls
+	}
+
+	testCases := []struct {
+		Name        string
+		TLS         *TLSConfig
+		ExpectError bool
+	}{
+		{
+			Name: "Empty acme section is valid",
+			TLS:  newTLS(&ACMEConfig{}),
+		},
+		{
+			Name: "DNS challenge with plugin and credentials is valid",
+			TLS:  newTLS(&ACMEConfig{Challenge: NewString(ACME_CHALLENGE_DNS), DNSPlugin: NewString("rfc2136"), DNSCredentialsFile: NewString("/etc/mattermost/rfc2136.ini")}),
+		},
+		{
+			Name:        "DNS challenge requires a plugin",
+			TLS:         newTLS(&ACMEConfig{Challenge: NewString(ACME_CHALLENGE_DNS)}),
+			ExpectError: true,
+		},
+		{
+			Name:        "DNS settings require the dns challenge",
+			TLS:         newTLS(&ACMEConfig{DNSPlugin: NewString("cloudflare")}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Directory URL must use https",
+			TLS:         newTLS(&ACMEConfig{DirectoryURL: NewString("http://ca.internal/acme/directory")}),
+			ExpectError: true,
+		},
+		{
+			Name:        "EAB key id requires the HMAC key",
+			TLS:         newTLS(&ACMEConfig{EABKeyID: NewString("kid")}),
+			ExpectError: true,
+		},
+		{
+			Name:        "Acme is only valid in letsencrypt mode",
+			TLS:         &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED), ACME: &ACMEConfig{}},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.TLS.SetDefaults()
+			err := tc.TLS.IsValid()
+			if tc.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCertbotArgs(t *testing.T) {
+	baseArgs := []string{"certonly", "--cert-name", "mattermost.example.com", "-d", "mattermost.example.com", "--renew-with-new-domains", "-n", "--agree-tos", "--email", "admin@example.com"}
+
+	t.Run("Should use the nginx plugin by default", func(t *testing.T) {
+		require.Equal(t, append(baseArgs, "--nginx"), withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com")}).CertbotArgs())
+	})
+
+	t.Run("Should request the aliases", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com")})
+		c.Aliases = []string{"chat.example.com"}
+		require.Equal(t, []string{"certonly", "--cert-name", "mattermost.example.com", "-d", "mattermost.example.com", "-d", "chat.example.com", "--renew-with-new-domains", "-n", "--agree-tos", "--email", "admin@example.com", "--nginx"}, c.CertbotArgs())
+	})
+
+	t.Run("Should use the dns plugin, server and EAB credentials", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), TLS: &TLSConfig{ACME: &ACMEConfig{
+			DirectoryURL:          NewString("https://ca.internal/acme/directory"),
+			Challenge:             NewString(ACME_CHALLENGE_DNS),
+			DNSPlugin:             NewString("cloudflare"),
+			DNSCredentialsFile:    NewString("/etc/mattermost/cloudflare.ini"),
+			DNSPropagationSeconds: NewInt(60),
+			EABKeyID:              NewString("kid"),
+			EABHMACKey:            NewString("hmac"),
+		}}})
+
+		require.Equal(t, append(baseArgs,
+			"--dns-cloudflare",
+			"--dns-cloudflare-credentials", "/etc/mattermost/cloudflare.ini",
+			"--dns-cloudflare-propagation-seconds", "60",
+			"--server", "https://ca.internal/acme/directory",
+			"--eab-kid", "kid", "--eab-hmac-key", "hmac",
+		), c.CertbotArgs())
+	})
+}
@@ -0,0 +1,233 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	TLS_POLICY_MODERN       = "modern"
+	TLS_POLICY_INTERMEDIATE = "intermediate"
+	TLS_POLICY_LEGACY       = "legacy"
+
+	// HSTS_PRELOAD_MIN_MAX_AGE is the minimum max-age accepted by the
+	// HSTS preload list
+	HSTS_PRELOAD_MIN_MAX_AGE = 31536000
+)
+
+// tlsPreset contains the protocols and ciphers of a TLS policy
+// preset, following the Mozilla server side TLS recommendations. The
+// DHE ciphers are left out as no DH parameters are configured
+type tlsPreset struct {
+	protocols           []string
+	ciphers             string
+	preferServerCiphers bool
+}
+
+var tlsPresets = map[string]*tlsPreset{
+	TLS_POLICY_MODERN: {
+		protocols: []string{"TLSv1.3"},
+	},
+	TLS_POLICY_INTERMEDIATE: {
+		protocols: []string{"TLSv1.2", "TLSv1.3"},
+		ciphers:   "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305",
+	},
+	TLS_POLICY_LEGACY: {
+		protocols:           []string{"TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"},
+		ciphers:             "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:ECDHE-ECDSA-AES128-SHA256:ECDHE-RSA-AES128-SHA256:ECDHE-ECDSA-AES128-SHA:ECDHE-RSA-AES128-SHA:ECDHE-ECDSA-AES256-SHA384:ECDHE-RSA-AES256-SHA384:ECDHE-ECDSA-AES256-SHA:ECDHE-RSA-AES256-SHA:AES128-GCM-SHA256:AES256-GCM-SHA384:AES128-SHA256:AES256-SHA256:AES128-SHA:AES256-SHA:DES-CBC3-SHA",
+		preferServerCiphers: true,
+	},
+}
+
+var tlsProtocols = []string{"TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"}
+
+// TLSPolicyConfig contains the TLS settings of NGINX. The preset
+// protocols and ciphers can be overridden. OCSP stapling is disabled
+// by default, as Let's Encrypt certificates don't include an OCSP
+// responder anymore
+type TLSPolicyConfig struct {
+	Preset       *string     `yaml:"preset"`
+	Protocols    []string    `yaml:"protocols,omitempty"`
+	Ciphers      *string     `yaml:"ciphers,omitempty"`
+	OCSPStapling *bool       `yaml:"ocsp_stapling"`
+	OCSPResolver *string     `yaml:"ocsp_resolver"`
+	HSTS         *HSTSConfig `yaml:"hsts"`
+}
+
+// HSTSConfig contains the Strict-Transport-Security header settings,
+// which is only sent when https is enabled
+type HSTSConfig struct {
+	Enabled           *bool `yaml:"enabled"`
+	MaxAge            *int  `yaml:"max_age"`
+	IncludeSubdomains *bool `yaml:"include_subdomains"`
+	Preload           *bool `yaml:"preload"`
+}
+
+func (p *TLSPolicyConfig) SetDefaults() {
+	if p.Preset == nil {
+		p.Preset = NewString(TLS_POLICY_INTERMEDIATE)
+	}
+
+	if p.OCSPStapling == nil {
+		p.OCSPStapling = NewBool(false)
+	}
+
+	if p.OCSPResolver == nil {
+		p.OCSPResolver = NewString("")
+	}
+
+	if p.HSTS == nil {
+		p.HSTS = &HSTSConfig{}
+	}
+	p.HSTS.SetDefaults()
+}
+
+func (p *TLSPolicyConfig) IsValid() error {
+	if _, ok := tlsPresets[*p.Preset]; !ok {
+		return fmt.Errorf("preset must be one of %q, %q or %q", TLS_POLICY_MODERN, TLS_POLICY_INTERMEDIATE, TLS_POLICY_LEGACY)
+	}
+
+	for _, protocol := range p.Protocols {
+		valid := false
+		for _, known := range tlsProtocols {
+			valid = valid || protocol == known
+		}
+		if !valid {
+			return fmt.Errorf("protocol %q must be one of %s", protocol, strings.Join(tlsProtocols, ", "))
+		}
+	}
+
+	if p.Ciphers != nil && (*p.Ciphers == "" || strings.ContainsAny(*p.Ciphers, " ;'\"{}\n")) {
+		return fmt.Errorf("ciphers must be a non empty OpenSSL cipher list separated by colons")
+	}
+
+	if strings.ContainsAny(*p.OCSPResolver, ";'\"{}\n") {
+		return fmt.Errorf("invalid ocsp_resolver %q", *p.OCSPResolver)
+	}
+
+	if err := p.HSTS.IsValid(); err != nil {
+		return fmt.Errorf("invalid hsts configuration: %w", err)
+	}
+
+	return nil
+}
+
+func (h *HSTSConfig) SetDefaults() {
+	if h.Enabled == nil {
+		h.Enabled = NewBool(true)
+	}
+
+	if h.MaxAge == nil {
+		h.MaxAge = NewInt(15768000)
+	}
+
+	if h.IncludeSubdomains == nil {
+		h.IncludeSubdomains = NewBool(false)
+	}
+
+	if h.Preload == nil {
+		h.Preload = NewBool(false)
+	}
+}
+
+func (h *HSTSConfig) IsValid() error {
+	if *h.MaxAge < 0 {
+		return fmt.Errorf("max_age cannot be negative")
+	}
+
+	if *h.Preload && (!*h.IncludeSubdomains || *h.MaxAge < HSTS_PRELOAD_MIN_MAX_AGE) {
+		return fmt.Errorf("preload requires include_subdomains and a max_age of at least %d", HSTS_PRELOAD_MIN_MAX_AGE)
+	}
+
+	return nil
+}
+
+// header returns the value of the Strict-Transport-Security header
+func (h *HSTSConfig) header() string {
+	value := "max-age=" + strconv.Itoa(*h.MaxAge)
+	if *h.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if *h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// tlsPolicy returns the TLS policy of the configuration, with the
+// default values if the tls_policy section is not set
+func (c *Config) tlsPolicy() *TLSPolicyConfig {
+	if c.TLSPolicy != nil {
+		return c.TLSPolicy
+	}
+
+	policy := &TLSPolicyConfig{}
+	policy.SetDefaults()
+	return policy
+}
+
+// ocspTrustedCertificate returns the certificate chain used to verify
+// the stapled OCSP responses, if known
+func (c *Config) ocspTrustedCertificate() string {
+	switch c.TLSMode() {
+	case TLS_MODE_LETSENCRYPT:
+		return filepath.Join(LETSENCRYPT_LIVE_DIR, *c.FQDN, "chain.pem")
+	case TLS_MODE_CUSTOM:
+		if c.TLS.ChainFile != nil {
+			return *c.TLS.ChainFile
+		}
+	}
+	return ""
+}
+
+// NginxTLSDirectives returns the NGINX directives that apply the TLS
+// policy to the https server blocks. Nothing is returned if https is
+// disabled, so the HSTS header is never sent over plain http
+func (c *Config) NginxTLSDirectives() []string {
+	if !*c.HTTPS {
+		return []string{}
+	}
+
+	policy := c.tlsPolicy()
+	preset := tlsPresets[*policy.Preset]
+
+	protocols := preset.protocols
+	if len(policy.Protocols) != 0 {
+		protocols = policy.Protocols
+	}
+
+	ciphers := preset.ciphers
+	if policy.Ciphers != nil {
+		ciphers = *policy.Ciphers
+	}
+
+	preferServerCiphers := "off"
+	if preset.preferServerCiphers {
+		preferServerCiphers = "on"
+	}
+
+	directives := []string{"ssl_protocols " + strings.Join(protocols, " ") + ";"}
+	if ciphers != "" {
+		directives = append(directives, "ssl_ciphers '"+ciphers+"';")
+	}
+	directives = append(directives, "ssl_prefer_server_ciphers "+preferServerCiphers+";")
+
+	// the certificates of the local CA have no OCSP responder
+	if *policy.OCSPStapling && c.TLSMode() != TLS_MODE_SELFSIGNED {
+		directives = append(directives, "ssl_stapling on;")
+		if trusted := c.ocspTrustedCertificate(); trusted != "" {
+			directives = append(directives, "ssl_stapling_verify on;", "ssl_trusted_certificate "+trusted+";")
+		}
+		if *policy.OCSPResolver != "" {
+			directives = append(directives, "resolver "+*policy.OCSPResolver+";")
+		}
+	}
+
+	if *policy.HSTS.Enabled {
+		directives = append(directives, "add_header Strict-Transport-Security \""+policy.HSTS.header()+"\" always;")
+	}
+
+	return directives
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNginxTLSDirectives(t *testing.T) {
+	t.Run("Should render each preset", func(t *testing.T) {
+		testCases := []struct {
+			Preset   string
+			Expected []string
+		}{
+			{
+				Preset: TLS_POLICY_MODERN,
+				Expected: []string{
+					"ssl_protocols TLSv1.3;",
+					"ssl_prefer_server_ciphers off;",
+					`add_header Strict-Transport-Security "max-age=15768000" always;`,
+				},
+			},
+			{
+				Preset: TLS_POLICY_INTERMEDIATE,
+				Expected: []string{
+					"ssl_protocols TLSv1.2 TLSv1.3;",
+					"ssl_ciphers '" + tlsPresets[TLS_POLICY_INTERMEDIATE].ciphers + "';",
+					"ssl_prefer_server_ciphers off;",
+					`add_header Strict-Transport-Security "max-age=15768000" always;`,
+				},
+			},
+			{
+				Preset: TLS_POLICY_LEGACY,
+				Expected: []string{
+					"ssl_protocols TLSv1 TLSv1.1 TLSv1.2 TLSv1.3;",
+					"ssl_ciphers '" + tlsPresets[TLS_POLICY_LEGACY].ciphers + "';",
+					"ssl_prefer_server_ciphers on;",
+					`add_header Strict-Transport-Security "max-age=15768000" always;`,
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.Preset, func(t *testing.T) {
+				c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true), TLSPolicy: &TLSPolicyConfig{Preset: NewString(tc.Preset)}})
+				require.NoError(t, c.IsValid())
+				require.Equal(t, tc.Expected, c.NginxTLSDirectives())
+			})
+		}
+	})
+
+	t.Run("Should default to the intermediate preset", func(t *testing.T) {
+		intermediate := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true), TLSPolicy: &TLSPolicyConfig{Preset: NewString(TLS_POLICY_INTERMEDIATE)}})
+		require.Equal(t, intermediate.NginxTLSDirectives(), withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true)}).NginxTLSDirectives())
+	})
+
+	t.Run("Should not render anything without https", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true)})
+		c.HTTPS = NewBool(false)
+		require.Empty(t, c.NginxTLSDirectives())
+	})
+
+	t.Run("Should apply overrides, OCSP stapling and HSTS settings", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true), TLSPolicy: &TLSPolicyConfig{
+			Protocols:    []string{"TLSv1.2"},
+			Ciphers:      NewString("ECDHE-RSA-AES128-GCM-SHA256"),
+			OCSPStapling: NewBool(true),
+			OCSPResolver: NewString("127.0.0.53"),
+			HSTS: &HSTSConfig{
+				MaxAge:            NewInt(63072000),
+				IncludeSubdomains: NewBool(true),
+				Preload:           NewBool(true),
+			},
+		}})
+		require.NoError(t, c.IsValid())
+		require.Equal(t, []string{
+			"ssl_protocols TLSv1.2;",
+			"ssl_ciphers 'ECDHE-RSA-AES128-GCM-SHA256';",
+			"ssl_prefer_server_ciphers off;",
+			"ssl_stapling on;",
+			"ssl_stapling_verify on;",
+			"ssl_trusted_certificate /etc/letsencrypt/live/mattermost.example.com/chain.pem;",
+			"resolver 127.0.0.53;",
+			`add_header Strict-Transport-Security "max-age=63072000; includeSubDomains; preload" always;`,
+		}, c.NginxTLSDirectives())
+	})
+
+	t.Run("Should skip OCSP stapling for selfsigned certificates and disable HSTS", func(t *testing.T) {
+		c := withDefaults(&Config{FQDN: NewString("mattermost.example.com"), Email: NewString("admin@example.com"), HTTPS: NewBool(true), TLS: &TLSConfig{Mode: NewString(TLS_MODE_SELFSIGNED)}, TLSPolicy: &TLSPolicyConfig{OCSPStapling: NewBool(true), HSTS: &HSTSConfig{Enabled: NewBool(false)}}})
+		require.Equal(t, []string{
+			"ssl_protocols TLSv1.2 TLSv1.3;",
+			"ssl_ciphers '" + tlsPresets[TLS_POLICY_INTERMEDIATE].ciphers + "';",
+			"ssl_prefer_server_ciphers off;",
+		}, c.NginxTLSDirectives())
+	})
+}
+
+func TestTLSPolicyIsValid(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Policy *TLSPolicyConfig
+	}{
+		{Name: "Unknown preset", Policy: &TLSPolicyConfig{Preset: NewString("strict")}},
+		{Name: "Unknown protocol", Policy: &TLSPolicyConfig{Protocols: []string{"SSLv3"}}},
+		{Name: "Ciphers with spaces", Policy: &TLSPolicyConfig{Ciphers: NewString("AES128 AES256")}},
+		{Name: "Ciphers injecting directives", Policy: &TLSPolicyConfig{Ciphers: NewString("AES128'; return 200;")}},
+		{Name: "Preload without subdomains", Policy: &TLSPolicyConfig{HSTS: &HSTSConfig{MaxAge: NewInt(63072000), Preload: NewBool(true)}}},
+		{Name: "Preload with short max age", Policy: &TLSPolicyConfig{HSTS: &HSTSConfig{IncludeSubdomains: NewBool(true), Preload: NewBool(true)}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Policy.SetDefaults()
+			require.Error(t, tc.Policy.IsValid())
+		})
+	}
+}
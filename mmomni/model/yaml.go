@@ -0,0 +1,158 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// marshal encodes the config as YAML. If the document that the config
+// was read from is provided, the config values are merged into it
+// instead, so comments, key ordering and unknown keys survive and
+// only the values that changed are touched
+func (c *Config) marshal(doc *yaml.Node) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, err
+	}
+
+	if doc == nil || len(doc.Content) == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}}
+	} else {
+		mergeNode(doc.Content[0], &node, reflect.TypeOf(c))
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// mergeNode updates dst in place with the contents of src. Mappings
+// are merged key by key: keys missing from dst are appended, values
+// that differ are replaced keeping their comments, and keys that are
+// part of t but missing from src are removed. Keys that t doesn't
+// know about are left untouched
+func mergeNode(dst, src *yaml.Node, t reflect.Type) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		if !nodesEqual(dst, src) {
+			replaceNode(dst, src)
+		}
+		return
+	}
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := yamlFields(t)
+	isKnown := func(key string) bool {
+		if t == nil || t.Kind() != reflect.Struct {
+			return true
+		}
+		_, ok := fields[key]
+		return ok
+	}
+
+	srcKeys := map[string]bool{}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		srcKeys[key.Value] = true
+
+		if dstValue := mappingValue(dst, key.Value); dstValue != nil {
+			mergeNode(dstValue, value, elemType(t, fields, key.Value))
+			continue
+		}
+		dst.Content = append(dst.Content, key, value)
+	}
+
+	content := dst.Content[:0]
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key := dst.Content[i].Value
+		if !srcKeys[key] && isKnown(key) {
+			continue
+		}
+		content = append(content, dst.Content[i], dst.Content[i+1])
+	}
+	dst.Content = content
+}
+
+// replaceNode overwrites dst with src, keeping the comments of dst
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// nodesEqual compares the values that two nodes represent, ignoring
+// their style, so "50M" and 50M are considered the same
+func nodesEqual(a, b *yaml.Node) bool {
+	var aValue, bValue interface{}
+	if err := a.Decode(&aValue); err != nil {
+		return false
+	}
+	if err := b.Decode(&bValue); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aValue, bValue)
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlFields returns the types of the fields of a struct indexed by
+// their yaml key
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// elemType returns the type of the value stored under key in a value
+// of type t
+func elemType(t reflect.Type, fields map[string]reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return fields[key]
+	case reflect.Map:
+		return t.Elem()
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package storage contains a minimal client for S3 compatible object
+// storage services, used to migrate the Mattermost files to a bucket
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	amzShortFormat = "20060102"
+	emptySHA256    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Client signs requests with AWS Signature Version 4 and uses path
+// style URLs, so it works with AWS and with self hosted services like
+// MinIO
+type S3Client struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SSL             bool
+	SSE             bool
+
+	HTTPClient *http.Client
+	now        func() time.Time
+}
+
+// ObjectInfo contains the metadata of a stored object
+type ObjectInfo struct {
+	Size int64
+	ETag string
+}
+
+func NewS3Client(endpoint, region, bucket, accessKeyID, secretAccessKey string, ssl, sse bool) *S3Client {
+	return &S3Client{
+		Endpoint:        endpoint,
+		Region:          region,
+		Bucket:          bucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SSL:             ssl,
+		SSE:             sse,
+		HTTPClient:      &http.Client{Timeout: 10 * time.Minute},
+		now:             time.Now,
+	}
+}
+
+// encodePath escapes each segment of an object path as required by
+// the canonical request of the signature
+func encodePath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (c *S3Client) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	scheme := "http"
+	if c.SSL {
+		scheme = "https"
+	}
+
+	path := "/" + c.Bucket + "/" + key
+	u := &url.URL{
+		Scheme:  scheme,
+		Host:    c.Endpoint,
+		Path:    path,
+		RawPath: encodePath(path),
+	}
+
+	return http.NewRequest(method, u.String(), body)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds the AWS Signature Version 4 authorization header to the
+// request, signing the host, the Content-MD5 and all the x-amz-*
+// headers
+func (c *S3Client) sign(req *http.Request, payloadHash string) {
+	t := c.now().UTC()
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "x-amz-") || lowerName == "content-md5" || lowerName == "range" {
+			headers[lowerName] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(amzShortFormat), c.Region, "s3", "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), t.Format(amzShortFormat))
+	signingKey = hmacSHA256(signingKey, c.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("unexpected response %q: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// HeadObject returns the metadata of an object, or nil if the object
+// doesn't exist
+func (c *S3Client) HeadObject(key string) (*ObjectInfo, error) {
+	req, err := c.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, emptySHA256)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	return &ObjectInfo{
+		Size: resp.ContentLength,
+		ETag: strings.Trim(resp.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+// PutObject uploads an object. The MD5 checksum is sent to the server
+// so it can verify the integrity of the upload, and the returned ETag
+// is checked against it
+func (c *S3Client) PutObject(key string, body io.Reader, size int64, md5sum, sha256sum []byte) error {
+	req, err := c.newRequest(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum))
+	if c.SSE {
+		req.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	}
+	c.sign(req, hex.EncodeToString(sha256sum))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	if etag != "" && etag != hex.EncodeToString(md5sum) {
+		return fmt.Errorf("checksum mismatch after uploading %q: expected %x, got %s", key, md5sum, etag)
+	}
+
+	return nil
+}